@@ -4,26 +4,25 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/http"
 	"github.com/werf/multiwerf/pkg/multiwerf"
 )
 
 var (
-	groupHelp        = "Selector of a release series. Examples: 1.0, 1.1, 1.2."
+	groupHelp        = fmt.Sprintf("Selector of a release series, the exact version or the semver constraint. Examples: 1.0, 1.1, 1.2, v1.2.37, ~1.2.30, '>=1.2.10 <1.3'. The project config %s is used if omitted.", multiwerf.ProjectConfigFilename)
 	groupHintOptions = []string{"1.0", "1.1", "1.2"}
 
-	channels = []string{
-		"alpha",
-		"beta",
-		"ea",
-		"stable",
-		"rock-solid",
-	}
-	channelHelp = fmt.Sprintf("The minimum acceptable level of stability. One of: %s.", strings.Join(channels, "|"))
+	channelsGroupHelp = "Print only the specified group. Examples: 1.0, 1.1, 1.2."
+
+	channels    = multiwerf.Channels
+	channelHelp = fmt.Sprintf("The minimum acceptable level of stability. One of: %s. Default: %s.", strings.Join(channels, "|"), multiwerf.DefaultChannel)
 	channelEnum = []string{
 		"alpha",
 		"beta",
@@ -57,12 +56,23 @@ func main() {
 
 	app.SetupGlobalSettings(kpApp)
 
+	// the application action is applied before command actions
+	kpApp.Action(func(_ *kingpin.ParseContext) error {
+		return http.SetupTLS(http.TLSOptions{
+			CABundlePath:   app.CABundlePath,
+			ClientCertPath: app.ClientCertPath,
+			ClientKeyPath:  app.ClientKeyPath,
+		})
+	})
+
 	updateCommand(kpApp)
 	selfUpdateCommand(kpApp)
 	useCommand(kpApp)
 	werfPathCommand(kpApp)
 	werfExecCommand(kpApp)
 	werfGCCommand(kpApp)
+	listCommand(kpApp)
+	channelsCommand(kpApp)
 	versionCommand(kpApp)
 
 	command, err := kpApp.Parse(os.Args[1:])
@@ -162,12 +172,11 @@ func updateCommand(kpApp *kingpin.Application) {
 	updateCmd := kpApp.
 		Command("update", "Perform self-update and download the actual channel werf binary.").
 		Action(func(c *kingpin.ParseContext) error {
-			channelStr = normalizeChannel(channelStr)
-
 			options := multiwerf.UpdateOptions{
 				SkipSelfUpdate:          selfUpdate == "no",
 				WithCache:               withCache,
 				WithGC:                  withGC == "yes",
+				GCOptions:               gcRetentionOptions(),
 				TryRemoteChannelMapping: update == "yes",
 				OutputFile:              updateOutputFile,
 			}
@@ -216,11 +225,9 @@ func updateCommand(kpApp *kingpin.Application) {
 		})
 	updateCmd.Arg("MAJOR.MINOR", groupHelp).
 		HintOptions(groupHintOptions...).
-		Required().
 		StringVar(&groupStr)
 	updateCmd.Arg("CHANNEL", channelHelp).
 		HintOptions(channels...).
-		Default("stable").
 		EnumVar(&channelStr, channelEnum...)
 	updateCmd.Flag("with-cache", "Cache remote channel mapping between updates.").
 		BoolVar(&withCache)
@@ -267,13 +274,13 @@ func useCommand(kpApp *kingpin.Application) {
 	useCmd := kpApp.
 		Command("use", "Generate the shell script that should be sourced to use the actual channel werf binary in the current shell session based on the local channel mapping.").
 		Action(func(c *kingpin.ParseContext) error {
-			channelStr = normalizeChannel(channelStr)
 			options := multiwerf.UseOptions{
 				ForceRemoteCheck:        forceRemoteCheck,
 				AsFile:                  asFile,
 				SkipSelfUpdate:          selfUpdate == "no",
 				TryRemoteChannelMapping: update == "yes",
 				WithGC:                  withGC == "yes",
+				GCOptions:               gcRetentionOptions(),
 			}
 
 			if value, err := getTryTrdlOption(tryTrdl); err != nil {
@@ -296,11 +303,9 @@ func useCommand(kpApp *kingpin.Application) {
 		})
 	useCmd.Arg("MAJOR.MINOR", groupHelp).
 		HintOptions(groupHintOptions...).
-		Required().
 		StringVar(&groupStr)
 	useCmd.Arg("CHANNEL", channelHelp).
 		HintOptions(channels...).
-		Default("stable").
 		EnumVar(&channelStr, channelEnum...)
 	useCmd.Flag("force-remote-check", "Do not use '--with-cache' option with background multiwerf update command.").
 		BoolVar(&forceRemoteCheck)
@@ -341,8 +346,6 @@ func werfPathCommand(kpApp *kingpin.Application) {
 	werfPathCmd := kpApp.
 		Command("werf-path", "Print the actual channel werf binary path based on the local channel mapping.").
 		Action(func(c *kingpin.ParseContext) error {
-			channelStr = normalizeChannel(channelStr)
-
 			tryTrdlOption, err := getTryTrdlOption(tryTrdl)
 			if err != nil {
 				return err
@@ -355,11 +358,9 @@ func werfPathCommand(kpApp *kingpin.Application) {
 		})
 	werfPathCmd.Arg("MAJOR.MINOR", groupHelp).
 		HintOptions(groupHintOptions...).
-		Required().
 		StringVar(&groupStr)
 	werfPathCmd.Arg("CHANNEL", channelHelp).
 		HintOptions(channels...).
-		Default("stable").
 		EnumVar(&channelStr, channelEnum...)
 	werfPathCmd.Flag("try-trdl", tryTrdlHelp).
 		Envar("MULTIWERF_TRY_TRDL").
@@ -378,13 +379,21 @@ func werfExecCommand(kpApp *kingpin.Application) {
 	werfExecCmd := kpApp.
 		Command("werf-exec", "Exec the actual channel werf binary based on the local channel mapping.").
 		Action(func(c *kingpin.ParseContext) error {
-			channelStr = normalizeChannel(channelStr)
-
 			tryTrdlOption, err := getTryTrdlOption(tryTrdl)
 			if err != nil {
 				return err
 			}
 
+			// positional arguments are split manually because MAJOR.MINOR and CHANNEL are optional,
+			// they are taken from the parse context to distinguish empty werf args from the omitted ones
+			var positionalArgs []string
+			for _, element := range c.Elements {
+				if _, ok := element.Clause.(*kingpin.ArgClause); ok {
+					positionalArgs = append(positionalArgs, *element.Value)
+				}
+			}
+			groupStr, channelStr, werfArgs = splitWerfExecArgs(positionalArgs)
+
 			if err := multiwerf.WerfExec(groupStr, channelStr, werfArgs, tryTrdlOption); err != nil {
 				os.Exit(1)
 			}
@@ -392,12 +401,10 @@ func werfExecCommand(kpApp *kingpin.Application) {
 		})
 	werfExecCmd.Arg("MAJOR.MINOR", groupHelp).
 		HintOptions(groupHintOptions...).
-		Required().
 		StringVar(&groupStr)
 	werfExecCmd.Arg("CHANNEL", channelHelp).
 		HintOptions(channels...).
-		Default("stable").
-		EnumVar(&channelStr, channelEnum...)
+		StringVar(&channelStr)
 	werfExecCmd.Arg("WERF_ARGS", "Pass args to werf binary.").
 		StringsVar(&werfArgs)
 	werfExecCmd.Flag("try-trdl", tryTrdlHelp).
@@ -406,17 +413,163 @@ func werfExecCommand(kpApp *kingpin.Application) {
 		StringVar(&tryTrdl)
 }
 
+// splitWerfExecArgs returns the group or the exact version, the channel and werf args.
+// The group and the channel are empty if the first argument is not a version selector.
+// Empty args are passed to werf as is.
+func splitWerfExecArgs(positionalArgs []string) (string, string, []string) {
+	if len(positionalArgs) == 0 || !multiwerf.IsVersionSelector(positionalArgs[0]) {
+		return "", "", positionalArgs
+	}
+
+	groupOrVersion, rest := positionalArgs[0], positionalArgs[1:]
+	if !multiwerf.IsExactVersion(groupOrVersion) && len(rest) != 0 && isChannel(rest[0]) {
+		return groupOrVersion, rest[0], rest[1:]
+	}
+
+	return groupOrVersion, "", rest
+}
+
+func isChannel(value string) bool {
+	for _, channel := range channelEnum {
+		if channel == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func werfGCCommand(kpApp *kingpin.Application) {
-	kpApp.
+	var (
+		dryRun            bool
+		tmpMaxAgeDays     int
+		scriptsMaxAgeDays int
+		logsMaxAgeDays    int
+	)
+
+	gcCmd := kpApp.
 		Command("gc", "Run garbage collection.").
 		Action(func(c *kingpin.ParseContext) error {
-			err := multiwerf.GC()
+			options := gcRetentionOptions()
+			options.DryRun = dryRun
+			options.TmpMaxAge = time.Duration(tmpMaxAgeDays) * 24 * time.Hour
+			options.ScriptsMaxAge = time.Duration(scriptsMaxAgeDays) * 24 * time.Hour
+			options.LogsMaxAge = time.Duration(logsMaxAgeDays) * 24 * time.Hour
+
+			if err := multiwerf.GC(options); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+	gcCmd.Flag("dry-run", "Print versions and files that would be removed and the space that would be freed without removing anything.").
+		BoolVar(&dryRun)
+	gcCmd.Flag("tmp-max-age-days", "Remove interrupted downloads, temporary files and old multiwerf binaries older than N days.").
+		Envar("MULTIWERF_GC_TMP_MAX_AGE_DAYS").
+		Default(strconv.Itoa(int(multiwerf.DefaultGCTmpMaxAge / (24 * time.Hour)))).
+		IntVar(&tmpMaxAgeDays)
+	gcCmd.Flag("scripts-max-age-days", "Remove scripts generated by multiwerf use --as-file that have not been used for N days.").
+		Envar("MULTIWERF_GC_SCRIPTS_MAX_AGE_DAYS").
+		Default(strconv.Itoa(int(multiwerf.DefaultGCScriptsMaxAge / (24 * time.Hour)))).
+		IntVar(&scriptsMaxAgeDays)
+	gcCmd.Flag("logs-max-age-days", "Remove logs that have not been written for N days, logs larger than 10 MiB are removed regardless of the age.").
+		Envar("MULTIWERF_GC_LOGS_MAX_AGE_DAYS").
+		Default(strconv.Itoa(int(multiwerf.DefaultGCLogsMaxAge / (24 * time.Hour)))).
+		IntVar(&logsMaxAgeDays)
+}
+
+// gcRetentionOptions returns global retention policies that are applied by gc and update --with-gc
+func gcRetentionOptions() multiwerf.GCOptions {
+	return multiwerf.GCOptions{
+		KeepPerGroup:   app.GCKeepPerGroup,
+		KeepUsedWithin: time.Duration(app.GCKeepUsedWithinDays) * 24 * time.Hour,
+		Keep:           app.GCKeep,
+		MaxStorage:     int64(app.GCMaxStorage),
+	}
+}
+
+func listCommand(kpApp *kingpin.Application) {
+	var format string
+
+	listCmd := kpApp.
+		Command("list", "List locally installed werf versions.").
+		Action(func(c *kingpin.ParseContext) error {
+			err := multiwerf.List(multiwerf.ListOptions{Format: format})
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+	listCmd.Flag("format", "Output format: table or json.").
+		Default(multiwerf.TableListFormat).
+		EnumVar(&format, multiwerf.TableListFormat, multiwerf.JSONListFormat)
+}
+
+func channelsCommand(kpApp *kingpin.Application) {
+	channelsCmd := kpApp.Command("channels", "Inspect the local channel mapping and its history.")
+
+	var (
+		groupStr string
+		remote   bool
+	)
+
+	showCmd := channelsCmd.
+		Command("show", "Print versions of groups and channels based on the local channel mapping.").
+		Default().
+		Action(func(c *kingpin.ParseContext) error {
+			err := multiwerf.PrintChannels(multiwerf.ChannelsOptions{Group: groupStr, Remote: remote})
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+	showCmd.Arg("MAJOR.MINOR", channelsGroupHelp).
+		HintOptions(groupHintOptions...).
+		StringVar(&groupStr)
+	showCmd.Flag("remote", "Fetch the remote channel mapping and show differences with the local one.").
+		BoolVar(&remote)
+
+	var historyGroupStr string
+
+	historyCmd := channelsCmd.
+		Command("history", "Print changes of the local channel mapping history.").
+		Action(func(c *kingpin.ParseContext) error {
+			err := multiwerf.PrintChannelsHistory(multiwerf.ChannelsHistoryOptions{Group: historyGroupStr})
 			if err != nil {
 				_, _ = fmt.Fprintln(os.Stderr, err)
 				os.Exit(1)
 			}
 			return nil
 		})
+	historyCmd.Arg("MAJOR.MINOR", channelsGroupHelp).
+		HintOptions(groupHintOptions...).
+		StringVar(&historyGroupStr)
+
+	var (
+		diffGroupStr string
+		from         int
+		to           int
+	)
+
+	diffCmd := channelsCmd.
+		Command("diff", "Print changes between two entries of the local channel mapping history. The latest change is printed by default.").
+		Action(func(c *kingpin.ParseContext) error {
+			err := multiwerf.PrintChannelsDiff(multiwerf.ChannelsDiffOptions{Group: diffGroupStr, From: from, To: to})
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return nil
+		})
+	diffCmd.Arg("MAJOR.MINOR", channelsGroupHelp).
+		HintOptions(groupHintOptions...).
+		StringVar(&diffGroupStr)
+	diffCmd.Flag("from", "The history entry ID to compare. The entry before --to by default.").
+		IntVar(&from)
+	diffCmd.Flag("to", "The history entry ID to compare with. The latest entry by default.").
+		IntVar(&to)
 }
 
 func versionCommand(kpApp *kingpin.Application) *kingpin.CmdClause {
@@ -425,12 +578,3 @@ func versionCommand(kpApp *kingpin.Application) *kingpin.CmdClause {
 		return nil
 	})
 }
-
-func normalizeChannel(value string) string {
-	switch value {
-	case "rc", "early-access":
-		return "ea"
-	default:
-		return value
-	}
-}
@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channel mapping conditional requests", func() {
+	const conditionalChannelMapping = "conditional-multiwerf.json"
+
+	setChannelMapping := func(fixtureName string) {
+		data, err := ioutil.ReadFile(fixturePath("channel_mapping", fixtureName))
+		Ω(err).ShouldNot(HaveOccurred())
+		releaseServer.SetChannelMapping(conditionalChannelMapping, data)
+	}
+
+	update := func() string {
+		return util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+	}
+
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(conditionalChannelMapping))
+		setChannelMapping(remoteChannelMapping1)
+	})
+
+	It("should use the local channel mapping if the remote one is not modified", func() {
+		update()
+
+		metaData, err := ioutil.ReadFile(filepath.Join(storageDir, "multiwerf.json.meta"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(metaData)).Should(ContainSubstring(releaseServer.ChannelMappingUrl(conditionalChannelMapping)))
+		Ω(string(metaData)).Should(ContainSubstring(`"etag"`))
+
+		notModifiedCount := releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)
+
+		output := update()
+		Ω(output).ShouldNot(ContainSubstring("Trying to get the local channel mapping"))
+		Ω(output).Should(ContainSubstring("The version v0.0.0 is the actual for channel 0.0/stable"))
+		Ω(releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)).Should(Equal(notModifiedCount + 1))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+
+		setChannelMapping(remoteChannelMapping2)
+
+		output = update()
+		Ω(output).Should(ContainSubstring("The version v0.0.1 is the actual for channel 0.0/stable"))
+		Ω(releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)).Should(Equal(notModifiedCount + 1))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+	})
+
+	It("should verify the not modified channel mapping again if the signature requirement has been changed", func() {
+		update()
+
+		data, err := ioutil.ReadFile(fixturePath("channel_mapping", remoteChannelMapping1))
+		Ω(err).ShouldNot(HaveOccurred())
+		releaseServer.SetUnsignedChannelMapping(conditionalChannelMapping, data)
+
+		notModifiedCount := releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)
+
+		stubs.SetEnv("MULTIWERF_REQUIRE_CHANNEL_MAPPING_SIGNATURE", "true")
+		output := update()
+		Ω(output).Should(ContainSubstring("the channel mapping is not signed"))
+		Ω(releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)).Should(Equal(notModifiedCount))
+	})
+
+	It("should not make the conditional request if the local channel mapping does not exist", func() {
+		update()
+
+		Ω(os.Remove(filepath.Join(storageDir, "multiwerf.json"))).Should(Succeed())
+		notModifiedCount := releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)
+
+		update()
+		Ω(releaseServer.ChannelMappingNotModifiedCount(conditionalChannelMapping)).Should(Equal(notModifiedCount))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+	})
+})
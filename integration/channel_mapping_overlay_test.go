@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channel mapping overlays", func() {
+	const overlayChannelMapping = "overlay-stable.json"
+
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+		// alpha v0.0.1 and stable v0.0.1, the overlay holds back stable on v0.0.0 as in the channel mapping 1
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping2))
+
+		data, err := ioutil.ReadFile(fixturePath("channel_mapping", overlayChannelMapping))
+		Ω(err).ShouldNot(HaveOccurred())
+		releaseServer.SetChannelMapping(overlayChannelMapping, data)
+	})
+
+	updateShouldUseOverlay := func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(output).Should(ContainSubstring("The version v0.0.0 is the actual for channel 0.0/stable"))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("channels", "0.0", "--remote")...,
+		)
+
+		Ω(output).ShouldNot(MatchRegexp(`(v[0-9.]+|-) -> (v[0-9.]+|-)`))
+	}
+
+	It("should override the remote channel mapping by the local overlay", func() {
+		overlayPath, err := filepath.Abs(fixturePath("channel_mapping", overlayChannelMapping))
+		Ω(err).ShouldNot(HaveOccurred())
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", overlayPath)
+
+		updateShouldUseOverlay()
+	})
+
+	It("should override the remote channel mapping by the remote overlay", func() {
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", releaseServer.ChannelMappingUrl(overlayChannelMapping))
+
+		updateShouldUseOverlay()
+	})
+
+	It("should apply overlays in order", func() {
+		overlayPath, err := filepath.Abs(fixturePath("channel_mapping", remoteChannelMapping2))
+		Ω(err).ShouldNot(HaveOccurred())
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", releaseServer.ChannelMappingUrl(overlayChannelMapping)+"\n"+overlayPath)
+
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+	})
+
+	It("should apply overlays to the local channel mapping if the remote one is not available", func() {
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+
+		overlayPath, err := filepath.Abs(fixturePath("channel_mapping", overlayChannelMapping))
+		Ω(err).ShouldNot(HaveOccurred())
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", overlayPath)
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl("not-found.json"))
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(output).Should(ContainSubstring("Trying to get the local channel mapping"))
+		Ω(output).Should(ContainSubstring("The version v0.0.0 is the actual for channel 0.0/stable"))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+	})
+
+	It("should keep the local channel mapping if the overlay is not available", func() {
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", releaseServer.ChannelMappingUrl(overlayChannelMapping))
+		updateShouldUseOverlay()
+
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_OVERLAYS", releaseServer.ChannelMappingUrl("not-found.json"))
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(output).Should(ContainSubstring("get the channel mapping overlay"))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+	})
+})
@@ -0,0 +1,68 @@
+package integration
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channel mapping signature", func() {
+	const unsignedChannelMapping = "unsigned-multiwerf.json"
+
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+		data, err := ioutil.ReadFile(fixturePath("channel_mapping", remoteChannelMapping2))
+		Ω(err).ShouldNot(HaveOccurred())
+		releaseServer.SetUnsignedChannelMapping(unsignedChannelMapping, data)
+	})
+
+	It("should verify the signed channel mapping", func() {
+		stubs.SetEnv("MULTIWERF_REQUIRE_CHANNEL_MAPPING_SIGNATURE", "true")
+
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+	})
+
+	It("should accept the unsigned channel mapping if the signature is not required", func() {
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(unsignedChannelMapping))
+
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+	})
+
+	It("should reject the unsigned channel mapping and keep the local one if the signature is required", func() {
+		stubs.SetEnv("MULTIWERF_REQUIRE_CHANNEL_MAPPING_SIGNATURE", "true")
+
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(unsignedChannelMapping))
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(output).Should(ContainSubstring("the channel mapping is not signed"))
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+	})
+})
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channel mapping validation", func() {
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+	})
+
+	It("should fail if the remote channel mapping is not valid and the local one does not exist", func() {
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMappingInvalid))
+
+		res, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "alpha")...,
+		)
+
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring("the channel mapping is not valid"))
+		Ω(filepath.Join(storageDir, "multiwerf.json")).ShouldNot(BeAnExistingFile())
+	})
+
+	It("should keep the local channel mapping if the remote one is not valid", func() {
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "alpha")...,
+		)
+
+		stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMappingInvalid))
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "alpha")...,
+		)
+
+		for _, substr := range []string{
+			"0.0/alpha: empty version",
+			"0.0/nightly: unknown channel",
+			"Trying to get the local channel mapping",
+			"The version v0.0.1 is the actual for channel 0.0/alpha",
+		} {
+			Ω(output).Should(ContainSubstring(substr))
+		}
+
+		multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
+	})
+})
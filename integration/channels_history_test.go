@@ -0,0 +1,47 @@
+package integration
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channels history and diff commands", func() {
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+		for _, channelMapping := range []string{remoteChannelMapping1, remoteChannelMapping2} {
+			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(channelMapping))
+			util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "alpha")...,
+			)
+		}
+	})
+
+	It("should print channel mapping changes", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("channels", "history", "0.0")...,
+		)
+
+		Ω(output).Should(MatchRegexp(`\n1\s+\S+ \S+\s+0\.0/alpha\s+-\s+v0\.0\.1\n`))
+		Ω(output).Should(MatchRegexp(`\n1\s+\S+ \S+\s+0\.0/stable\s+-\s+v0\.0\.0\n`))
+		Ω(output).Should(MatchRegexp(`\n2\s+\S+ \S+\s+0\.0/stable\s+v0\.0\.0\s+v0\.0\.1\n`))
+	})
+
+	It("should print the latest change", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("channels", "diff")...,
+		)
+
+		Ω(output).Should(ContainSubstring("Changes from #1"))
+		Ω(output).Should(MatchRegexp(`0\.0/stable\s+v0\.0\.0\s+v0\.0\.1\s+changed`))
+		Ω(output).ShouldNot(ContainSubstring("0.0/alpha"))
+	})
+})
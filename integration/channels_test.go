@@ -0,0 +1,66 @@
+package integration
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("channels command", func() {
+	It("should fail without the local channel mapping", func() {
+		res, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("channels")...,
+		)
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring("Run command `multiwerf update`"))
+	})
+
+	It("should print the remote channel mapping", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("channels", "0.0", "--remote")...,
+		)
+
+		Ω(output).Should(MatchRegexp(`GROUP\s+ALPHA\s`))
+		Ω(output).Should(MatchRegexp(`0\.0\s+- -> v0\.0\.1\s`))
+	})
+
+	When("the local channel mapping exists", func() {
+		BeforeEach(func() {
+			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+			util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "alpha")...,
+			)
+
+			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping2))
+		})
+
+		It("should print the local channel mapping with installed versions", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("channels")...,
+			)
+
+			Ω(output).Should(MatchRegexp(`GROUP\s+ALPHA\s+.*STABLE\s`))
+			Ω(output).Should(MatchRegexp(`0\.0\s+v0\.0\.1 \*\s+.*v0\.0\.0\s`))
+		})
+
+		It("should show differences with the remote channel mapping", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("channels", "--remote")...,
+			)
+
+			Ω(output).Should(MatchRegexp(`0\.0\s+.*v0\.0\.0 -> v0\.0\.1 \*`))
+		})
+	})
+})
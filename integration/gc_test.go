@@ -1,6 +1,14 @@
 package integration
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -8,6 +16,39 @@ import (
 )
 
 var _ = Describe("gc command", func() {
+	// versions installed by exact versions are pinned and kept by GC
+	unpinVersions := func() {
+		usageIndexPath := filepath.Join(storageDir, "usage.json")
+		data, err := ioutil.ReadFile(usageIndexPath)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var index map[string]interface{}
+		Ω(json.Unmarshal(data, &index)).Should(Succeed())
+		delete(index, "pinned")
+
+		data, err = json.Marshal(index)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ioutil.WriteFile(usageIndexPath, data, 0644)).Should(Succeed())
+	}
+
+	usedVersions := func() map[string]interface{} {
+		var index struct {
+			Versions map[string]interface{} `json:"versions"`
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(storageDir, "usage.json"))
+		if err == nil {
+			_ = json.Unmarshal(data, &index)
+		}
+
+		return index.Versions
+	}
+
+	BeforeEach(func() {
+		// ~/.multiwerf/trdl/log is collected by GC
+		stubs.SetEnv("HOME", filepath.Join(testDirPath, "home"))
+	})
+
 	It("should do nothing", func() {
 		output := util_test.SucceedCommandOutputString(
 			testDirPath,
@@ -47,6 +88,77 @@ var _ = Describe("gc command", func() {
 			}
 		})
 
+		It("should only print versions that would be removed in dry-run mode", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--dry-run")...,
+			)
+
+			for _, substr := range []string{
+				"GC: Would remove version v0.0.0",
+				"GC: Would remove version v0.0.1",
+				"GC: Would remove version v0.1.0",
+				"would be freed",
+			} {
+				Ω(output).Should(ContainSubstring(substr))
+			}
+
+			Ω(output).ShouldNot(ContainSubstring("GC: Removing version"))
+
+			for _, version := range []string{"v0.0.0", "v0.0.1", "v0.1.0"} {
+				Ω(filepath.Join(storageDir, version)).Should(BeADirectory())
+			}
+		})
+
+		It("should keep versions by retention policies", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--keep", "v0.0.0", "--keep-per-group", "1")...,
+			)
+
+			for _, substr := range []string{
+				"GC: Keeping version v0.0.0: in the keep list",
+				"GC: Keeping version v0.0.1: one of 1 highest versions of the group",
+				"GC: Keeping version v0.1.0: one of 1 highest versions of the group",
+				"GC: Nothing to clean",
+			} {
+				Ω(output).Should(ContainSubstring(substr))
+			}
+		})
+
+		It("should apply retention policies on update --with-gc", func() {
+			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+			stubs.SetEnv("MULTIWERF_GC_KEEP", "v0.1.0")
+
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "stable", "--keep-used-within-days", "1")...,
+			)
+
+			for _, substr := range []string{
+				"GC: Keeping version v0.1.0: in the keep list",
+				"GC: Keeping version v0.0.0: used at",
+				"GC: Keeping version v0.0.1: used at",
+			} {
+				Ω(output).Should(ContainSubstring(substr))
+			}
+
+			Ω(output).ShouldNot(ContainSubstring("GC: Removing version"))
+		})
+
+		It("should fail with a bad keep value", func() {
+			output, err := util_test.RunCommand(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--keep", "stable")...,
+			)
+			Ω(err).Should(HaveOccurred())
+			Ω(string(output)).Should(ContainSubstring("bad --keep value"))
+		})
+
 		When("multiwerf.json exists", func() {
 			BeforeEach(func() {
 				util_test.CopyIn(fixturePath("gc", "multiwerf_json_exist"), storageDir)
@@ -92,4 +204,185 @@ var _ = Describe("gc command", func() {
 			})
 		})
 	})
+
+	When("versions are installed and used", func() {
+		dirSize := func(path string) int64 {
+			var size int64
+			Ω(filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() {
+					size += info.Size()
+				}
+				return err
+			})).Should(Succeed())
+			return size
+		}
+
+		BeforeEach(func() {
+			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+			for _, version := range []string{"v0.0.1", "v0.0.0"} {
+				util_test.RunSucceedCommand(
+					testDirPath,
+					multiwerfBinPath,
+					multiwerfArgs("update", version)...,
+				)
+			}
+
+			util_test.RunSucceedCommand(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-path", "v0.0.1")...,
+			)
+
+			unpinVersions()
+		})
+
+		It("should record the usage of the resolved version", func() {
+			versions := usedVersions()
+			Ω(versions).Should(HaveKey("v0.0.1"))
+			Ω(versions).ShouldNot(HaveKey("v0.0.0"))
+		})
+
+		It("should remove the least recently used versions to fit the storage budget", func() {
+			maxStorage := dirSize(filepath.Join(storageDir, "v0.0.1"))
+			if size := dirSize(filepath.Join(storageDir, "v0.0.0")); size > maxStorage {
+				maxStorage = size
+			}
+
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--keep-used-within-days", "1", "--max-storage", fmt.Sprintf("%dB", maxStorage))...,
+			)
+
+			Ω(output).Should(ContainSubstring("GC: Removing version v0.0.0 ... (least recently used at"))
+			Ω(output).ShouldNot(ContainSubstring("GC: Removing version v0.0.1"))
+			Ω(filepath.Join(storageDir, "v0.0.0")).ShouldNot(BeADirectory())
+			Ω(filepath.Join(storageDir, "v0.0.1")).Should(BeADirectory())
+
+			data, err := ioutil.ReadFile(filepath.Join(storageDir, "usage.json"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(data)).Should(ContainSubstring(`"v0.0.1"`))
+		})
+
+		It("should apply the storage budget on update --with-gc", func() {
+			stubs.SetEnv("MULTIWERF_GC_KEEP_USED_WITHIN_DAYS", "1")
+			stubs.SetEnv("MULTIWERF_GC_MAX_STORAGE", fmt.Sprintf("%dB", dirSize(filepath.Join(storageDir, "v0.0.1"))))
+
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "alpha")...,
+			)
+
+			Ω(output).Should(ContainSubstring("GC: Removing version v0.0.0 ... (least recently used at"))
+			Ω(filepath.Join(storageDir, "v0.0.1")).Should(BeADirectory())
+		})
+	})
+
+	When("the version is running", func() {
+		BeforeEach(func() {
+			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+			util_test.RunSucceedCommand(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "v0.0.0")...,
+			)
+		})
+
+		It("should not remove the version used by werf-exec", func() {
+			cmd := exec.Command(multiwerfBinPath, multiwerfArgs("werf-exec", "v0.0.0", "sleep", "5s")...)
+			cmd.Dir = testDirPath
+			Ω(cmd.Start()).Should(Succeed())
+			defer func() { _ = cmd.Wait() }()
+
+			Eventually(usedVersions, "5s", "100ms").Should(HaveKey("v0.0.0"))
+			unpinVersions()
+
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc")...,
+			)
+
+			Ω(output).Should(ContainSubstring("GC: Skipped version v0.0.0"))
+			Ω(filepath.Join(storageDir, "v0.0.0")).Should(BeADirectory())
+		})
+	})
+
+	When("stale files exist", func() {
+		old := time.Now().Add(-60 * 24 * time.Hour)
+		writeOldFile := func(path string) {
+			Ω(os.MkdirAll(filepath.Dir(path), 0755)).Should(Succeed())
+			Ω(ioutil.WriteFile(path, []byte("data"), 0644)).Should(Succeed())
+			Ω(os.Chtimes(path, old, old)).Should(Succeed())
+		}
+
+		BeforeEach(func() {
+			writeOldFile(filepath.Join(storageDir, "tmp", "v0.0.1-123", "werf"))
+			Ω(os.Chtimes(filepath.Join(storageDir, "tmp", "v0.0.1-123"), old, old)).Should(Succeed())
+			writeOldFile(filepath.Join(storageDir, "tmp", ".multiwerf.old"))
+			writeOldFile(filepath.Join(storageDir, "scripts", "0.0-stable", "werf_source"))
+			writeOldFile(filepath.Join(storageDir, "multiwerf_use_background_update.log"))
+			writeOldFile(filepath.Join(testDirPath, "home", ".multiwerf", "trdl", "log"))
+		})
+
+		It("should report stale files in dry-run mode", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--dry-run")...,
+			)
+
+			for _, substr := range []string{
+				"GC: Temporary files: 1 would be removed",
+				"GC: Old multiwerf binaries: 1 would be removed",
+				"GC: Use scripts: 1 would be removed",
+				"GC: Logs: 2 would be removed",
+			} {
+				Ω(output).Should(ContainSubstring(substr))
+			}
+
+			Ω(filepath.Join(storageDir, "tmp", ".multiwerf.old")).Should(BeAnExistingFile())
+		})
+
+		It("should remove stale files", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc")...,
+			)
+
+			for _, substr := range []string{
+				"GC: Temporary files: 1 of 1 removed",
+				"GC: Old multiwerf binaries: 1 of 1 removed",
+				"GC: Use scripts: 1 of 1 removed",
+				"GC: Logs: 2 of 2 removed",
+			} {
+				Ω(output).Should(ContainSubstring(substr))
+			}
+
+			for _, path := range []string{
+				filepath.Join(storageDir, "tmp", "v0.0.1-123"),
+				filepath.Join(storageDir, "tmp", ".multiwerf.old"),
+				filepath.Join(storageDir, "scripts", "0.0-stable"),
+				filepath.Join(storageDir, "multiwerf_use_background_update.log"),
+				filepath.Join(testDirPath, "home", ".multiwerf", "trdl", "log"),
+			} {
+				_, err := os.Stat(path)
+				Ω(os.IsNotExist(err)).Should(BeTrue(), path)
+			}
+		})
+
+		It("should keep files younger than thresholds", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("gc", "--tmp-max-age-days", "90", "--scripts-max-age-days", "90", "--logs-max-age-days", "90")...,
+			)
+
+			Ω(output).Should(ContainSubstring("GC: Nothing to clean"))
+		})
+	})
 })
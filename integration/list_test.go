@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/multiwerf"
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("list command", func() {
+	listLocalVersions := func() []*multiwerf.LocalVersion {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("list", "--format", "json")...,
+		)
+
+		var localVersions []*multiwerf.LocalVersion
+		Ω(json.Unmarshal([]byte(output), &localVersions)).Should(Succeed())
+
+		return localVersions
+	}
+
+	It("should print nothing", func() {
+		Ω(listLocalVersions()).Should(BeEmpty())
+	})
+
+	When("local versions and channel mappings exist", func() {
+		BeforeEach(func() {
+			util_test.CopyIn(fixturePath("gc", "local_versions_exist"), storageDir)
+			util_test.CopyIn(fixturePath("gc", "multiwerf_json_exist"), storageDir)
+			util_test.CopyIn(fixturePath("gc", "multiwerf_json_old_exist"), storageDir)
+		})
+
+		It("should print versions with channels", func() {
+			localVersions := listLocalVersions()
+			Ω(localVersions).Should(HaveLen(3))
+
+			for ind, version := range []string{"v0.0.0", "v0.0.1", "v0.1.0"} {
+				Ω(localVersions[ind].Version).Should(Equal(version))
+				Ω(localVersions[ind].Status).Should(Equal(multiwerf.NotVerifiedVersionStatus))
+			}
+
+			Ω(localVersions[0].Channels).Should(BeEmpty())
+			Ω(localVersions[0].OldChannels).Should(BeEmpty())
+			Ω(localVersions[1].OldChannels).Should(Equal([]string{"0.0/alpha"}))
+			Ω(localVersions[2].Channels).Should(Equal([]string{"0.0/alpha"}))
+		})
+
+		It("should print empty channels of versions without channels", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("list", "--format", "json")...,
+			)
+
+			Ω(output).ShouldNot(ContainSubstring("null"))
+		})
+	})
+
+	When("the version is installed", func() {
+		BeforeEach(func() {
+			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+		})
+
+		It("should print the verified version", func() {
+			util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "alpha")...,
+			)
+
+			localVersions := listLocalVersions()
+			Ω(localVersions).Should(HaveLen(1))
+			Ω(localVersions[0].Version).Should(Equal("v0.0.1"))
+			Ω(localVersions[0].Status).Should(Equal(multiwerf.VerifiedVersionStatus))
+			Ω(localVersions[0].Size).Should(BeNumerically(">", 0))
+			Ω(localVersions[0].Channels).Should(ContainElement("0.0/alpha"))
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/werf/multiwerf/pkg/app"
 	"github.com/werf/multiwerf/pkg/multiwerf"
 	"github.com/werf/multiwerf/pkg/util_test"
 )
@@ -17,7 +18,7 @@ var _ = Describe("other commands", func() {
 	When("local channel mapping and the actual channel version exist", func() {
 		BeforeEach(func() {
 			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
-			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", remoteChannelMapping1Url)
+			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping1))
 			util_test.RunSucceedCommand(
 				testDirPath,
 				multiwerfBinPath,
@@ -36,7 +37,7 @@ var _ = Describe("other commands", func() {
 				storageDir,
 				actualAlphaVersion1,
 				multiwerf.ReleaseProgramFilename(
-					"werf-test",
+					app.AppPackageName,
 					actualAlphaVersion1,
 					strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-"),
 				),
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/multiwerf"
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("project config", func() {
+	var projectSubDirPath string
+
+	writeProjectConfig := func(content string) {
+		Ω(ioutil.WriteFile(filepath.Join(testDirPath, multiwerf.ProjectConfigFilename), []byte(content), 0644)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+		projectSubDirPath = filepath.Join(testDirPath, "project", "subdir")
+		Ω(os.MkdirAll(projectSubDirPath, os.ModePerm)).Should(Succeed())
+	})
+
+	It("should fail without arguments and the project config", func() {
+		res, err := util_test.RunCommand(
+			projectSubDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-path")...,
+		)
+
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring("MAJOR.MINOR is required"))
+	})
+
+	When("the project config declares the group and the channel", func() {
+		BeforeEach(func() {
+			writeProjectConfig("group: \"0.0\"\nchannel: alpha\n")
+		})
+
+		It("should update and exec the channel version", func() {
+			util_test.RunSucceedCommand(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update")...,
+			)
+
+			output := util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-exec", "version")...,
+			)
+			Ω(output).Should(BeEquivalentTo(actualAlphaVersion1 + "\n"))
+
+			output = util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("use", "--as-file")...,
+			)
+			Ω(output).Should(ContainSubstring(filepath.Join("scripts", "0.0-alpha")))
+		})
+
+		It("should prefer command line arguments", func() {
+			util_test.RunSucceedCommand(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "stable")...,
+			)
+
+			output := util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-exec", "0.0", "stable", "--", "version")...,
+			)
+			Ω(output).Should(BeEquivalentTo(actualStableVersion1 + "\n"))
+		})
+	})
+
+	When("the project config declares the exact version", func() {
+		BeforeEach(func() {
+			writeProjectConfig("version: v0.1.0\n")
+		})
+
+		It("should update and exec the exact version", func() {
+			output := util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update")...,
+			)
+			Ω(output).Should(ContainSubstring("Downloading the version v0.1.0"))
+			Ω(filepath.Join(storageDir, "multiwerf.json")).ShouldNot(BeAnExistingFile(), "the channel mapping should not be used")
+
+			output = util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-exec", "version")...,
+			)
+			Ω(output).Should(BeEquivalentTo("v0.1.0\n"))
+		})
+
+		It("should pass empty werf args as is", func() {
+			util_test.RunSucceedCommand(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update")...,
+			)
+
+			output := util_test.SucceedCommandOutputString(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-exec", "", "build")...,
+			)
+			Ω(output).Should(BeEquivalentTo("fake werf v0.1.0: [\"\" \"build\"]\n"))
+		})
+
+		It("should pass werf args that look like bare wildcards as is", func() {
+			util_test.RunSucceedCommand(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update")...,
+			)
+
+			for _, arg := range []string{"x", "*"} {
+				output := util_test.SucceedCommandOutputString(
+					projectSubDirPath,
+					multiwerfBinPath,
+					multiwerfArgs("werf-exec", arg)...,
+				)
+				Ω(output).Should(BeEquivalentTo(fmt.Sprintf("fake werf v0.1.0: [%q]\n", arg)))
+			}
+		})
+
+		It("werf-path should fail if the version is not downloaded", func() {
+			res, err := util_test.RunCommand(
+				projectSubDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("werf-path")...,
+			)
+
+			Ω(err).Should(HaveOccurred())
+			Ω(string(res)).Should(ContainSubstring("Run command `multiwerf update v0.1.0`"))
+		})
+	})
+})
@@ -18,7 +18,9 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
 
+	"github.com/werf/multiwerf/pkg/app"
 	"github.com/werf/multiwerf/pkg/multiwerf"
 	"github.com/werf/multiwerf/pkg/util_test"
 )
@@ -32,22 +34,91 @@ var testDirPath string
 var tmpDir string
 var storageDir string
 var multiwerfBinPath string
+var selfUpdateMultiwerfBinPath string
+var releaseServer *util_test.ReleaseServer
+var keyringDir string
 var stubs = gostub.New()
 
 const (
-	remoteChannelMapping1Url = "https://gist.githubusercontent.com/alexey-igrychev/a01d19ce9924761cb457fdedd83bcd6c/raw/a16a7c4b0c6adfb03e4f7a88754fc4325e320f68/multiwerf-1.json"
-	remoteChannelMapping2Url = "https://gist.githubusercontent.com/alexey-igrychev/a01d19ce9924761cb457fdedd83bcd6c/raw/2a7afbd0f272dfa088887ff40c18e0468fbc16f2/multiwerf-2.json"
+	remoteChannelMapping1 = "multiwerf-1.json"
+	remoteChannelMapping2 = "multiwerf-2.json"
+	// the channel mapping with the empty version and the unknown channel
+	remoteChannelMappingInvalid = "multiwerf-invalid.json"
 
 	actualAlphaVersion1  = "v0.0.1"
 	actualStableVersion1 = "v0.0.0"
 	actualStableVersion2 = "v0.0.1"
+
+	latestMultiwerfVersion = "v9.9.9"
 )
 
+var werfVersions = []string{"v0.0.0", "v0.0.1", "v0.1.0"}
+
+type suiteBinPaths struct {
+	Multiwerf           string `json:"multiwerf"`
+	SelfUpdateMultiwerf string `json:"self_update_multiwerf"`
+	LatestMultiwerf     string `json:"latest_multiwerf"`
+	FakeWerf            string `json:"fake_werf"`
+	SigningKey          []byte `json:"signing_key"`
+}
+
 var _ = SynchronizedBeforeSuite(func() []byte {
-	computedPathToMultiwerf := util_test.ProcessMultiwerfBinPath()
-	return []byte(computedPathToMultiwerf)
-}, func(computedPathToMultiwerf []byte) {
-	multiwerfBinPath = string(computedPathToMultiwerf)
+	// the same signing key is used by release servers of all nodes and pinned in the self-updated binaries
+	signingKey := util_test.NewSigningKey()
+	pinnedSigningKey := util_test.PinnedSigningKey(signingKey)
+
+	data, err := json.Marshal(suiteBinPaths{
+		Multiwerf:           util_test.ProcessMultiwerfBinPath(),
+		SelfUpdateMultiwerf: util_test.BuildMultiwerfBinPathWithSigningKey(pinnedSigningKey, ""),
+		LatestMultiwerf:     util_test.BuildMultiwerfBinPathWithSigningKey(pinnedSigningKey, latestMultiwerfVersion),
+		FakeWerf:            util_test.BuildFakeWerfBinPath(),
+		SigningKey:          signingKey,
+	})
+	Ω(err).ShouldNot(HaveOccurred())
+
+	return data
+}, func(data []byte) {
+	var binPaths suiteBinPaths
+	Ω(json.Unmarshal(data, &binPaths)).Should(Succeed())
+
+	multiwerfBinPath = binPaths.Multiwerf
+	selfUpdateMultiwerfBinPath = binPaths.SelfUpdateMultiwerf
+
+	// channel mappings and release files are served locally to run the suite offline
+	releaseServer = util_test.NewReleaseServer(binPaths.SigningKey)
+
+	for _, name := range []string{remoteChannelMapping1, remoteChannelMapping2, remoteChannelMappingInvalid} {
+		data, err := ioutil.ReadFile(fixturePath("channel_mapping", name))
+		Ω(err).ShouldNot(HaveOccurred())
+		releaseServer.SetChannelMapping(name, data)
+	}
+
+	osArch := strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-")
+
+	fakeWerfData, err := ioutil.ReadFile(binPaths.FakeWerf)
+	Ω(err).ShouldNot(HaveOccurred())
+	for _, version := range werfVersions {
+		releaseServer.AddRelease(app.AppPackageName, version, map[string][]byte{
+			multiwerf.ReleaseProgramFilename(app.AppPackageName, version, osArch): fakeWerfData,
+		})
+	}
+
+	latestMultiwerfData, err := ioutil.ReadFile(binPaths.LatestMultiwerf)
+	Ω(err).ShouldNot(HaveOccurred())
+	releaseServer.AddRelease(app.SelfPackageName, latestMultiwerfVersion, map[string][]byte{
+		multiwerf.ReleaseProgramFilename(app.SelfPackageName, latestMultiwerfVersion, osArch): latestMultiwerfData,
+	})
+
+	keyringDir, err = util_test.GetTempDir()
+	Ω(err).ShouldNot(HaveOccurred())
+	releaseServer.WriteKeyring(filepath.Join(keyringDir, "keyring.gpg"))
+})
+
+var _ = SynchronizedAfterSuite(func() {
+	releaseServer.Close()
+	Ω(os.RemoveAll(keyringDir)).Should(Succeed())
+}, func() {
+	gexec.CleanupBuildArtifacts()
 })
 
 var _ = BeforeEach(func() {
@@ -60,13 +131,13 @@ var _ = BeforeEach(func() {
 	storageDir = filepath.Join(testDirPath, "storage_dir")
 	stubs.SetEnv("MULTIWERF_STORAGE_DIR", storageDir)
 
-	stubs.SetEnv("MULTIWERF_SELF_BINTRAY_SUBJECT", "flant")
-	stubs.SetEnv("MULTIWERF_SELF_BINTRAY_REPO", "multiwerf")
-	stubs.SetEnv("MULTIWERF_SELF_BINTRAY_PACKAGE", "multiwerf-test")
+	stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping1))
+	stubs.SetEnv("MULTIWERF_REPOS", releaseServer.ReposConfig())
+	stubs.SetEnv("MULTIWERF_TRUSTED_KEYRING", filepath.Join(keyringDir, "keyring.gpg"))
+	stubs.SetEnv("MULTIWERF_REQUIRE_SIGNATURES", "true")
 
-	stubs.SetEnv("MULTIWERF_BINTRAY_SUBJECT", "flant")
-	stubs.SetEnv("MULTIWERF_BINTRAY_REPO", "multiwerf")
-	stubs.SetEnv("MULTIWERF_BINTRAY_PACKAGE", "werf-test")
+	stubs.SetEnv("MULTIWERF_TRY_TRDL", "no")
+	stubs.SetEnv("MULTIWERF_AUTO_INSTALL_TRDL", "no")
 })
 
 var _ = AfterEach(func() {
@@ -115,14 +186,14 @@ func storageTmpDirShouldBeEmpty() {
 }
 
 func releaseFilesShouldBeExist(version string) {
-	files := multiwerf.ReleaseFiles("werf-test", version, strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-"))
+	files := multiwerf.RequiredReleaseFiles(multiwerf.ReleaseFiles(app.AppPackageName, version, strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-")))
 	for _, filename := range files {
 		Ω(filepath.Join(storageDir, version, filename)).Should(BeARegularFile(), fmt.Sprintf("the release files for channel should be downloaded to %s folder", version))
 	}
 }
 
-func getFormatRemoteChannelMappingData(remoteChannelMappingUrl string) []byte {
-	resp, err := http.Get(remoteChannelMappingUrl)
+func getFormatRemoteChannelMappingData(remoteChannelMapping string) []byte {
+	resp, err := http.Get(releaseServer.ChannelMappingUrl(remoteChannelMapping))
 	Ω(err).ShouldNot(HaveOccurred())
 	defer resp.Body.Close()
 
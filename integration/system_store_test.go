@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/multiwerf"
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("system store", func() {
+	var systemStoreDir string
+
+	programPath := func(dir, version string) string {
+		return filepath.Join(dir, version, multiwerf.ReleaseProgramFilename(
+			app.AppPackageName,
+			version,
+			strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-"),
+		))
+	}
+
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+
+		// the system store is prepopulated by the update with the storage dir pointing to it
+		systemStoreDir = filepath.Join(testDirPath, "system_store")
+		stubs.SetEnv("MULTIWERF_STORAGE_DIR", systemStoreDir)
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.0")...,
+		)
+
+		stubs.SetEnv("MULTIWERF_STORAGE_DIR", storageDir)
+		stubs.SetEnv("MULTIWERF_SYSTEM_STORE_DIR", systemStoreDir)
+	})
+
+	It("should use the version from the system store", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.0")...,
+		)
+		Ω(output).ShouldNot(ContainSubstring("Downloading the version v0.0.0"))
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-path", "v0.0.0")...,
+		)
+		Ω(strings.TrimSpace(output)).Should(Equal(programPath(systemStoreDir, "v0.0.0")))
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-exec", "~0.0.0", "--", "version")...,
+		)
+		Ω(output).Should(BeEquivalentTo("v0.0.0\n"))
+
+		Ω(filepath.Join(storageDir, "v0.0.0")).ShouldNot(BeADirectory())
+	})
+
+	It("should download missing versions into the storage dir and gc should not touch the system store", func() {
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.1")...,
+		)
+		Ω(programPath(storageDir, "v0.0.1")).Should(BeAnExistingFile())
+
+		// the pinned version is kept
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("gc")...,
+		)
+		Ω(output).Should(ContainSubstring("GC: Local versions:  [v0.0.1]"))
+		Ω(output).Should(ContainSubstring("GC: Keeping version v0.0.1: pinned"))
+
+		Ω(programPath(systemStoreDir, "v0.0.0")).Should(BeAnExistingFile())
+	})
+
+	It("should ignore the corrupted version in the system store", func() {
+		Ω(ioutil.WriteFile(programPath(systemStoreDir, "v0.0.0"), []byte("corrupted"), os.ModePerm)).Should(Succeed())
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.0")...,
+		)
+		Ω(output).Should(ContainSubstring("has invalid or corrupted files and is ignored"))
+		Ω(output).Should(ContainSubstring("Downloading the version v0.0.0"))
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-path", "v0.0.0")...,
+		)
+		Ω(strings.TrimSpace(output)).Should(Equal(programPath(storageDir, "v0.0.0")))
+	})
+
+	It("should fail if the system store dir is the storage dir", func() {
+		stubs.SetEnv("MULTIWERF_SYSTEM_STORE_DIR", storageDir)
+
+		output, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("gc")...,
+		)
+		Ω(err).Should(HaveOccurred())
+		Ω(string(output)).Should(ContainSubstring("should differ from the storage dir"))
+	})
+})
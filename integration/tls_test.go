@@ -0,0 +1,44 @@
+package integration
+
+import (
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("TLS settings", func() {
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+	})
+
+	It("should fail if the CA bundle is not valid", func() {
+		caBundlePath := tmpPath("ca.pem")
+		Ω(ioutil.WriteFile(caBundlePath, []byte("not a certificate"), 0644)).Should(Succeed())
+		stubs.SetEnv("MULTIWERF_CA_BUNDLE", caBundlePath)
+
+		res, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring("unable to parse CA bundle"))
+	})
+
+	It("should fail if the client certificate is set without the key", func() {
+		stubs.SetEnv("MULTIWERF_CLIENT_CERT", tmpPath("client.pem"))
+
+		res, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "0.0", "stable")...,
+		)
+
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring("both the client certificate and the client key should be set"))
+	})
+})
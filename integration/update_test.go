@@ -3,13 +3,17 @@ package integration
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/multiwerf"
 	"github.com/werf/multiwerf/pkg/util_test"
 )
 
@@ -18,9 +22,10 @@ var _ = Describe("update command", func() {
 		var multiwerfForSelfUpdateBinPath string
 
 		BeforeEach(func() {
+			// the binary with the pinned signing key of the release server
 			testDirBinPath := filepath.Join(testDirPath, "bin")
-			util_test.CopyIn(filepath.Dir(multiwerfBinPath), testDirBinPath)
-			multiwerfForSelfUpdateBinPath = filepath.Join(testDirBinPath, filepath.Base(multiwerfBinPath))
+			util_test.CopyIn(filepath.Dir(selfUpdateMultiwerfBinPath), testDirBinPath)
+			multiwerfForSelfUpdateBinPath = filepath.Join(testDirBinPath, filepath.Base(selfUpdateMultiwerfBinPath))
 
 			stubs.SetEnv("MULTIWERF_SELF_UPDATE", "yes")
 		})
@@ -69,6 +74,64 @@ var _ = Describe("update command", func() {
 
 			Ω(newVersion).ShouldNot(BeEquivalentTo(version), "multiwerf version should be changed")
 		})
+
+		It("should skip self-update without the pinned signing key", func() {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0")...,
+			)
+
+			Ω(output).Should(ContainSubstring("Self-update: Detect version"))
+			Ω(output).Should(ContainSubstring("Skip Self-update: The signing key is not pinned in this build"))
+			Ω(output).ShouldNot(ContainSubstring("Signing key error"))
+			Ω(output).ShouldNot(ContainSubstring("Self-update: Successfully updated to"))
+		})
+
+		// * multiwerf version should not be changed
+		// * the downloaded binary should be removed
+		DescribeTable("should not be self-updated if the release is not verified",
+			func(fileName string, modify func(data []byte) []byte, expectedOutput string) {
+				data := releaseServer.ReleaseFile(app.SelfPackageName, latestMultiwerfVersion, fileName)
+				releaseServer.SetReleaseFile(app.SelfPackageName, latestMultiwerfVersion, fileName, modify(data))
+				defer releaseServer.SetReleaseFile(app.SelfPackageName, latestMultiwerfVersion, fileName, data)
+
+				version := util_test.SucceedCommandOutputString(
+					testDirPath,
+					multiwerfForSelfUpdateBinPath,
+					multiwerfArgs("version")...,
+				)
+
+				output, _ := util_test.RunCommand(
+					testDirPath,
+					multiwerfForSelfUpdateBinPath,
+					multiwerfArgs("update", "0.0")...,
+				)
+				Ω(string(output)).Should(ContainSubstring(expectedOutput))
+				Ω(string(output)).ShouldNot(ContainSubstring("Self-update: Successfully updated to"))
+
+				newVersion := util_test.SucceedCommandOutputString(
+					testDirPath,
+					multiwerfForSelfUpdateBinPath,
+					multiwerfArgs("version")...,
+				)
+				Ω(newVersion).Should(BeEquivalentTo(version), "multiwerf version should not be changed")
+
+				osArch := strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-")
+				programFileName := multiwerf.ReleaseProgramFilename(app.SelfPackageName, latestMultiwerfVersion, osArch)
+				Ω(filepath.Join(filepath.Dir(multiwerfForSelfUpdateBinPath), programFileName)).ShouldNot(BeAnExistingFile(), "the downloaded binary should be removed")
+			},
+			Entry("the signature does not exist", "SHA256SUMS.sig", func(_ []byte) []byte {
+				return nil
+			}, "Self-update: Download SHA256SUMS.sig error"),
+			Entry("the signature is made with another key", "SHA256SUMS.sig", func(_ []byte) []byte {
+				sha256sums := releaseServer.ReleaseFile(app.SelfPackageName, latestMultiwerfVersion, "SHA256SUMS")
+				return util_test.DetachSign(util_test.NewSigningKey(), sha256sums)
+			}, "Self-update: SHA256SUMS signature verification error"),
+			Entry("SHA256SUMS is tampered", "SHA256SUMS", func(data []byte) []byte {
+				return append(append([]byte{}, data...), []byte("0000  multiwerf-tampered\n")...)
+			}, "Self-update: SHA256SUMS signature verification error"),
+		)
 	})
 
 	When("--self-update=no", func() {
@@ -88,7 +151,7 @@ var _ = Describe("update command", func() {
 		// third step is relaunching update when remote channel mapping has been changed
 		threeStepsItFunc := func(e ItEntry) {
 			By("first step is running update with empty multiwerf storage dir")
-			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", remoteChannelMapping1Url)
+			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping1))
 			output := util_test.SucceedCommandOutputString(
 				testDirPath,
 				multiwerfBinPath,
@@ -105,7 +168,7 @@ var _ = Describe("update command", func() {
 			e.checksAfterSecondStep(output)
 
 			By("third step is relaunching update when remote channel mapping has been changed")
-			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", remoteChannelMapping2Url)
+			stubs.SetEnv("MULTIWERF_CHANNEL_MAPPING_URL", releaseServer.ChannelMappingUrl(remoteChannelMapping2))
 			output = util_test.SucceedCommandOutputString(
 				testDirPath,
 				multiwerfBinPath,
@@ -137,7 +200,7 @@ var _ = Describe("update command", func() {
 					Ω(output).Should(ContainSubstring(substr))
 				}
 
-				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1Url)
+				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping1)
 				Ω(filepath.Join(storageDir, "multiwerf.json.old")).ShouldNot(BeAnExistingFile())
 				releaseFilesShouldBeExist(actualStableVersion1)
 				storageTmpDirShouldBeEmpty()
@@ -163,8 +226,8 @@ var _ = Describe("update command", func() {
 					Ω(output).Should(ContainSubstring(substr))
 				}
 
-				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2Url)
-				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json.old"), remoteChannelMapping1Url)
+				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json"), remoteChannelMapping2)
+				multiwerfJsonShouldBeEqualRemoteChannelMapping(filepath.Join(storageDir, "multiwerf.json.old"), remoteChannelMapping1)
 				releaseFilesShouldBeExist(actualStableVersion2)
 				storageTmpDirShouldBeEmpty()
 			},
@@ -213,13 +276,48 @@ var _ = Describe("update command", func() {
 			Entry("--update=no", updateNoEntry),
 			Entry("--with-cache", withCacheEntry),
 		)
+
+		When("signatures are not required", func() {
+			BeforeEach(func() {
+				stubs.SetEnv("MULTIWERF_REQUIRE_SIGNATURES", "false")
+				stubs.SetEnv("MULTIWERF_DOWNLOAD_RETRIES", "0")
+			})
+
+			It("should skip verification of the unsigned release", func() {
+				data := releaseServer.ReleaseFile(app.AppPackageName, "v0.0.0", "SHA256SUMS.sig")
+				releaseServer.SetReleaseFile(app.AppPackageName, "v0.0.0", "SHA256SUMS.sig", nil)
+				defer releaseServer.SetReleaseFile(app.AppPackageName, "v0.0.0", "SHA256SUMS.sig", data)
+
+				output := util_test.SucceedCommandOutputString(
+					testDirPath,
+					multiwerfBinPath,
+					multiwerfArgs("update", "v0.0.0")...,
+				)
+				Ω(output).Should(ContainSubstring("The release is not signed"))
+			})
+
+			It("should fail if the signature is unavailable", func() {
+				releaseServer.SetReleaseFileStatus(app.AppPackageName, "v0.0.0", "SHA256SUMS.sig", http.StatusServiceUnavailable)
+				defer releaseServer.SetReleaseFileStatus(app.AppPackageName, "v0.0.0", "SHA256SUMS.sig", 0)
+
+				res, err := util_test.RunCommand(
+					testDirPath,
+					multiwerfBinPath,
+					multiwerfArgs("update", "v0.0.0")...,
+				)
+				Ω(err).Should(HaveOccurred())
+				Ω(string(res)).Should(ContainSubstring("downloading the signature of the version v0.0.0 failed"))
+				Ω(string(res)).ShouldNot(ContainSubstring("The release is not signed"))
+				Ω(filepath.Join(storageDir, "v0.0.0")).ShouldNot(BeAnExistingFile())
+			})
+		})
 	})
 })
 
-func multiwerfJsonShouldBeEqualRemoteChannelMapping(multiwerfJsonFilePath, remoteChannelMappingUrl string) {
+func multiwerfJsonShouldBeEqualRemoteChannelMapping(multiwerfJsonFilePath, remoteChannelMapping string) {
 	Ω(multiwerfJsonFilePath).Should(BeARegularFile(), "remote channel mapping should be downloaded to multiwerf.json")
 
 	data, err := ioutil.ReadFile(multiwerfJsonFilePath)
 	Ω(err).ShouldNot(HaveOccurred(), "remote channel mapping should be downloaded to multiwerf.json")
-	Ω(string(data)).Should(BeEquivalentTo(string(getFormatRemoteChannelMappingData(remoteChannelMappingUrl))), "remote channel mapping should be downloaded to multiwerf.json")
+	Ω(string(data)).Should(BeEquivalentTo(string(getFormatRemoteChannelMappingData(remoteChannelMapping))), "remote channel mapping should be downloaded to multiwerf.json")
 }
@@ -9,6 +9,8 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/werf/lockgate/pkg/util"
+
 	"github.com/werf/multiwerf/pkg/util_test"
 )
 
@@ -20,11 +22,12 @@ var _ = Describe("use command", func() {
 			multiwerfArgs("use", "0.0", "alpha", "--as-file")...,
 		)
 
+		// the script name has the hash suffix of the update args that differ from the group and channel
 		expectedPath := filepath.Join(
 			storageDir,
 			"scripts",
 			"0.0-alpha",
-			"werf_source",
+			"werf_source_"+util.MurmurHash("0.0 alpha --try-trdl=no"),
 		)
 
 		Ω(output).Should(BeEquivalentTo(expectedPath + "\n"))
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/multiwerf/pkg/util_test"
+)
+
+var _ = Describe("exact version and semver constraint selectors", func() {
+	BeforeEach(func() {
+		stubs.SetEnv("MULTIWERF_SELF_UPDATE", "no")
+	})
+
+	It("should update and exec the exact version", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.0")...,
+		)
+		Ω(output).Should(ContainSubstring("Downloading the version v0.0.0"))
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-exec", "v0.0.0", "--", "version")...,
+		)
+		Ω(output).Should(BeEquivalentTo("v0.0.0\n"))
+	})
+
+	It("should pass empty werf args as is", func() {
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "v0.0.0")...,
+		)
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-exec", "v0.0.0", "--", "", "")...,
+		)
+		Ω(output).Should(BeEquivalentTo("fake werf v0.0.0: [\"\" \"\"]\n"))
+	})
+
+	It("should update and exec the highest version matching the constraint", func() {
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", ">=0.0.0 <0.1")...,
+		)
+		Ω(output).Should(ContainSubstring(`The version v0.0.1 is the actual for constraint ">=0.0.0 <0.1"`))
+
+		output = util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-exec", "~0.0.0", "--", "version")...,
+		)
+		Ω(output).Should(BeEquivalentTo("v0.0.1\n"))
+	})
+
+	It("gc on update should keep the pinned version missing in the channel mapping", func() {
+		util_test.RunSucceedCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "~0.1.0")...,
+		)
+
+		for i := 0; i < 2; i++ {
+			output := util_test.SucceedCommandOutputString(
+				testDirPath,
+				multiwerfBinPath,
+				multiwerfArgs("update", "0.0", "alpha")...,
+			)
+			Ω(output).Should(ContainSubstring("GC: Keeping version v0.1.0: pinned by the exact version or the constraint"))
+		}
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("werf-exec", "v0.1.0", "--", "version")...,
+		)
+		Ω(output).Should(BeEquivalentTo("v0.1.0\n"))
+	})
+
+	It("should fail if no version matches the constraint", func() {
+		res, err := util_test.RunCommand(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "~0.2")...,
+		)
+
+		Ω(err).Should(HaveOccurred())
+		Ω(string(res)).Should(ContainSubstring(`no version matches constraint "~0.2"`))
+	})
+
+	It("should try the next repository if no version matches the constraint in the first one", func() {
+		releaseServer.SetPackageIndex("mirror/werf", []string{"v0.0.0"})
+		stubs.SetEnv("MULTIWERF_REPOS", fmt.Sprintf(
+			`[{"type": "http", "endpoint": "%s/mirror/{package}"}, {"type": "http", "endpoint": "%s/{package}"}]`,
+			releaseServer.URL, releaseServer.URL,
+		))
+
+		output := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("update", "~0.1.0")...,
+		)
+		Ω(output).Should(ContainSubstring(`No version matches constraint "~0.1.0"`))
+		Ω(output).Should(ContainSubstring(`The version v0.1.0 is the actual for constraint "~0.1.0"`))
+	})
+
+	It("use should generate the script with the quoted constraint", func() {
+		scriptPath := util_test.SucceedCommandOutputString(
+			testDirPath,
+			multiwerfBinPath,
+			multiwerfArgs("use", ">=0.0.0 <0.1", "--as-file")...,
+		)
+
+		data, err := ioutil.ReadFile(strings.TrimSpace(scriptPath))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(ContainSubstring("multiwerf werf-path '>=0.0.0 <0.1'"))
+	})
+})
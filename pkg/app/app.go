@@ -1,11 +1,14 @@
 package app
 
 import (
+	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/alecthomas/units"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -23,24 +26,80 @@ var SelfBintrayPackage = "multiwerf"
 var BintraySubject = "flant"
 var BintrayRepo = "werf"
 var BintrayPackage = "werf"
+var HTTPRepoUrl string
+var SelfHTTPRepoUrl string
+var HTTPRepoIndexUrlTemplate string
+var HTTPRepoFileUrlTemplate string
+
+var S3Endpoint string
+var S3Region string
+var S3ForcePathStyle bool
+var S3ReleasesFolder string
+var S3Credentials string
+var S3AccessKeyID string
+var S3SecretAccessKey string
+var S3Profile string
+
+var Repos string
+var ReposConfigPath string
+
+var TrustedKeyringPath string
+var RequireSignatures bool
+var RequireChannelMappingSignature bool
+
+// Settings of the client for channel mappings and signatures
+var HTTPConnectTimeout = 10 * time.Second
+var HTTPTimeout = 30 * time.Second
+var UserAgent string
+
+// TLS settings of all connections
+var CABundlePath string
+var ClientCertPath string
+var ClientKeyPath string
+
+var DownloadConnectTimeout = 30 * time.Second
+var DownloadReadTimeout = 60 * time.Second
+var DownloadRetries = 5
+
+// Retention policies of GC that is run by gc and update --with-gc
+var GCKeepPerGroup int
+var GCKeepUsedWithinDays int
+var GCKeep []string
+var GCMaxStorage units.Base2Bytes
+
 var OsArch = strings.Join([]string{runtime.GOOS, runtime.GOARCH}, "-")
 var StorageDir = "~/.multiwerf"
 
+// SystemStoreDir is the read-only store of versions shared by all users of the host, e.g. /opt/multiwerf/store.
+// It has the same layout as StorageDir and is searched first.
+var SystemStoreDir string
+
 var SelfPackageName = "multiwerf"
+
+// SelfSigningKey is a base64 encoded OpenPGP public key that multiwerf releases are signed with.
+// It is pinned at build time.
+var SelfSigningKey = ""
 var AppPackageName = "werf"
 
 var ChannelMappingUrl = "https://raw.githubusercontent.com/werf/werf/multiwerf/multiwerf.json"
 var ChannelMappingPath string
 
+// Channel mappings that override versions of the remote channel mapping per group/channel in order
+var ChannelMappingOverlays []string
+
+// Reject the remote channel mapping if a more stable channel has a greater version than a less stable one
+var ChannelMappingCheckStabilityOrder bool
+
+// The number of channel mappings kept in the channel mapping history
+var ChannelMappingHistoryLimit = 20
+
 var DebugMessages = "no"
 var DebugMessagesFakeVar = "no"
 var Update = "yes"
 
-// An hour delay between checks for the latest version of werf
-var UpdateDelay = time.Hour
-
-// A 5 minute delay for alpha and beta releases
-var AlphaBetaUpdateDelay = time.Minute * 5
+// A 5 minute delay between checks of the remote channel mapping with --with-cache.
+// The channel mapping is requested conditionally, so the check is cheap if it is not modified.
+var ChannelMappingUpdateDelay = time.Minute * 5
 
 // 2 hour delay between check for the latest version of multiwerf
 var SelfUpdateDelay = 2 * time.Hour
@@ -63,6 +122,20 @@ func SetupGlobalSettings(kpApp *kingpin.Application) {
 		Default(ChannelMappingPath).
 		StringVar(&ChannelMappingPath)
 
+	kpApp.Flag("channel-mapping-overlay", "The URL or the path to the channel mapping that overrides versions of the remote channel mapping per group/channel (can be used multiple times, later overlays take precedence). Overlays are also applied to the local channel mapping if the remote one is not available.").
+		Envar("MULTIWERF_CHANNEL_MAPPING_OVERLAYS").
+		StringsVar(&ChannelMappingOverlays)
+
+	kpApp.Flag("channel-mapping-check-stability-order", "Reject the remote channel mapping if a more stable channel has a greater version than a less stable one (rock-solid ≤ stable ≤ ea ≤ beta ≤ alpha).").
+		Envar("MULTIWERF_CHANNEL_MAPPING_CHECK_STABILITY_ORDER").
+		BoolVar(&ChannelMappingCheckStabilityOrder)
+
+	kpApp.Flag("channel-mapping-history-limit", "The number of channel mappings kept in the local channel mapping history.").
+		Hidden().
+		Envar("MULTIWERF_CHANNEL_MAPPING_HISTORY_LIMIT").
+		Default(strconv.Itoa(ChannelMappingHistoryLimit)).
+		IntVar(&ChannelMappingHistoryLimit)
+
 	kpApp.Flag("bintray-subject", "The bintray api subject part for downloading werf release files.").
 		Hidden().
 		Envar("MULTIWERF_BINTRAY_SUBJECT").
@@ -99,6 +172,149 @@ func SetupGlobalSettings(kpApp *kingpin.Application) {
 		Default(SelfBintrayPackage).
 		StringVar(&SelfBintrayPackage)
 
+	kpApp.Flag("http-repo-url", "The base URL of the HTTP repository for downloading werf release files. The repository is tried first if set.").
+		Envar("MULTIWERF_HTTP_REPO_URL").
+		Default(HTTPRepoUrl).
+		StringVar(&HTTPRepoUrl)
+
+	kpApp.Flag("multiwerf-http-repo-url", "The base URL of the HTTP repository for downloading multiwerf release files. The repository is tried first if set.").
+		Envar("MULTIWERF_SELF_HTTP_REPO_URL").
+		Default(SelfHTTPRepoUrl).
+		StringVar(&SelfHTTPRepoUrl)
+
+	kpApp.Flag("s3-endpoint", "The S3 endpoint for downloading release files (default s3.yandexcloud.net).").
+		Hidden().
+		Envar("MULTIWERF_S3_ENDPOINT").
+		Default(S3Endpoint).
+		StringVar(&S3Endpoint)
+
+	kpApp.Flag("s3-region", "The S3 region (default ru-central1).").
+		Hidden().
+		Envar("MULTIWERF_S3_REGION").
+		Default(S3Region).
+		StringVar(&S3Region)
+
+	kpApp.Flag("s3-force-path-style", "Use path-style addressing of S3 buckets, e.g. for MinIO.").
+		Hidden().
+		Envar("MULTIWERF_S3_FORCE_PATH_STYLE").
+		BoolVar(&S3ForcePathStyle)
+
+	kpApp.Flag("s3-releases-folder", "The S3 folder with release versions (default targets/releases).").
+		Hidden().
+		Envar("MULTIWERF_S3_RELEASES_FOLDER").
+		Default(S3ReleasesFolder).
+		StringVar(&S3ReleasesFolder)
+
+	kpApp.Flag("s3-credentials", "The S3 credentials source: anonymous, static (--s3-access-key-id and --s3-secret-access-key), env (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY) or profile (--s3-profile from the shared credentials file).").
+		Hidden().
+		Envar("MULTIWERF_S3_CREDENTIALS").
+		Default("anonymous").
+		EnumVar(&S3Credentials, "anonymous", "static", "env", "profile")
+
+	kpApp.Flag("s3-access-key-id", "The S3 access key id for static credentials.").
+		Hidden().
+		Envar("MULTIWERF_S3_ACCESS_KEY_ID").
+		Default(S3AccessKeyID).
+		StringVar(&S3AccessKeyID)
+
+	kpApp.Flag("s3-secret-access-key", "The S3 secret access key for static credentials.").
+		Hidden().
+		Envar("MULTIWERF_S3_SECRET_ACCESS_KEY").
+		Default(S3SecretAccessKey).
+		StringVar(&S3SecretAccessKey)
+
+	kpApp.Flag("s3-profile", "The profile from the shared credentials file for profile credentials.").
+		Hidden().
+		Envar("MULTIWERF_S3_PROFILE").
+		Default(S3Profile).
+		StringVar(&S3Profile)
+
+	kpApp.Flag("repos", "The ordered repository chain for downloading release files in JSON format: a list of objects with type (s3, bintray or http), package, endpoint, bucket and priority fields.").
+		Envar("MULTIWERF_REPOS").
+		Default(Repos).
+		StringVar(&Repos)
+
+	kpApp.Flag("repos-config", "The path to the file with the ordered repository chain in the same format as --repos.").
+		Envar("MULTIWERF_REPOS_CONFIG").
+		Default(ReposConfigPath).
+		StringVar(&ReposConfigPath)
+
+	kpApp.Flag("http-repo-index-url-template", "The template of the HTTP repository index URL with the versions list (default {base}/index.json).").
+		Hidden().
+		Envar("MULTIWERF_HTTP_REPO_INDEX_URL_TEMPLATE").
+		Default(HTTPRepoIndexUrlTemplate).
+		StringVar(&HTTPRepoIndexUrlTemplate)
+
+	kpApp.Flag("http-repo-file-url-template", "The template of the HTTP repository release file URL (default {base}/{version}/{file}).").
+		Hidden().
+		Envar("MULTIWERF_HTTP_REPO_FILE_URL_TEMPLATE").
+		Default(HTTPRepoFileUrlTemplate).
+		StringVar(&HTTPRepoFileUrlTemplate)
+
+	kpApp.Flag("trusted-keyring", "The path to the armored or binary OpenPGP keyring with trusted keys to verify SHA256SUMS.sig signatures of werf releases and the channel mapping signature.").
+		Envar("MULTIWERF_TRUSTED_KEYRING").
+		Default(TrustedKeyringPath).
+		StringVar(&TrustedKeyringPath)
+
+	kpApp.Flag("require-signatures", "Refuse werf releases without a valid SHA256SUMS.sig signature.").
+		Envar("MULTIWERF_REQUIRE_SIGNATURES").
+		BoolVar(&RequireSignatures)
+
+	kpApp.Flag("require-channel-mapping-signature", "Refuse the remote channel mapping without a valid detached signature <channel-mapping-url>.sig.").
+		Envar("MULTIWERF_REQUIRE_CHANNEL_MAPPING_SIGNATURE").
+		BoolVar(&RequireChannelMappingSignature)
+
+	kpApp.Flag("http-connect-timeout", "The timeout for connecting to the host of the channel mapping.").
+		Hidden().
+		Envar("MULTIWERF_HTTP_CONNECT_TIMEOUT").
+		Default(HTTPConnectTimeout.String()).
+		DurationVar(&HTTPConnectTimeout)
+
+	kpApp.Flag("http-timeout", "The total timeout of the channel mapping request.").
+		Hidden().
+		Envar("MULTIWERF_HTTP_TIMEOUT").
+		Default(HTTPTimeout.String()).
+		DurationVar(&HTTPTimeout)
+
+	kpApp.Flag("ca-bundle", "The path to PEM encoded CA certificates that are trusted in addition to system ones for all connections.").
+		Envar("MULTIWERF_CA_BUNDLE").
+		Default(CABundlePath).
+		StringVar(&CABundlePath)
+
+	kpApp.Flag("client-cert", "The path to the PEM encoded client certificate for mutual TLS, should be used with --client-key.").
+		Envar("MULTIWERF_CLIENT_CERT").
+		Default(ClientCertPath).
+		StringVar(&ClientCertPath)
+
+	kpApp.Flag("client-key", "The path to the PEM encoded key of the client certificate.").
+		Envar("MULTIWERF_CLIENT_KEY").
+		Default(ClientKeyPath).
+		StringVar(&ClientKeyPath)
+
+	kpApp.Flag("user-agent", "The User-Agent header of multiwerf requests.").
+		Hidden().
+		Envar("MULTIWERF_USER_AGENT").
+		Default(fmt.Sprintf("multiwerf/%s", Version)).
+		StringVar(&UserAgent)
+
+	kpApp.Flag("download-connect-timeout", "The timeout for connecting to the repository when downloading release files.").
+		Hidden().
+		Envar("MULTIWERF_DOWNLOAD_CONNECT_TIMEOUT").
+		Default(DownloadConnectTimeout.String()).
+		DurationVar(&DownloadConnectTimeout)
+
+	kpApp.Flag("download-read-timeout", "The timeout for receiving the next chunk of a release file, the download is retried after it.").
+		Hidden().
+		Envar("MULTIWERF_DOWNLOAD_READ_TIMEOUT").
+		Default(DownloadReadTimeout.String()).
+		DurationVar(&DownloadReadTimeout)
+
+	kpApp.Flag("download-retries", "The number of retries of a failed release file download, the partially downloaded file is resumed.").
+		Hidden().
+		Envar("MULTIWERF_DOWNLOAD_RETRIES").
+		Default(strconv.Itoa(DownloadRetries)).
+		IntVar(&DownloadRetries)
+
 	// Default for os-arch is set at compile time
 	kpApp.Flag("os-arch", "The pair of os and arch of binary separated by dash").
 		Hidden().
@@ -112,6 +328,29 @@ func SetupGlobalSettings(kpApp *kingpin.Application) {
 		Default(StorageDir).
 		StringVar(&StorageDir)
 
+	kpApp.Flag("system-store-dir", "The read-only directory with versions shared by all users, e.g. /opt/multiwerf/store. Versions are searched there first and verified before use, missing ones are downloaded into the storage dir.").
+		Envar("MULTIWERF_SYSTEM_STORE_DIR").
+		StringVar(&SystemStoreDir)
+
+	kpApp.Flag("keep-per-group", "Keep N highest local versions in every MAJOR.MINOR group on GC.").
+		Envar("MULTIWERF_GC_KEEP_PER_GROUP").
+		Default("0").
+		IntVar(&GCKeepPerGroup)
+
+	kpApp.Flag("keep-used-within-days", "Keep versions that have been used within N days on GC.").
+		Envar("MULTIWERF_GC_KEEP_USED_WITHIN_DAYS").
+		Default("0").
+		IntVar(&GCKeepUsedWithinDays)
+
+	kpApp.Flag("keep", "Keep the exact version vMAJOR.MINOR.PATCH or versions matching the semver constraint on GC (can be specified multiple times).").
+		Envar("MULTIWERF_GC_KEEP").
+		StringsVar(&GCKeep)
+
+	kpApp.Flag("max-storage", "Remove the least recently used versions on GC until local versions fit the disk budget, e.g. 2GiB. Versions from the keep list and versions of the local channel mapping are never removed.").
+		Envar("MULTIWERF_GC_MAX_STORAGE").
+		Default("0").
+		BytesVar(&GCMaxStorage)
+
 	kpApp.Flag("debug", "Set to 'yes' to turn on debug messages.").
 		Envar("MULTIWERF_DEBUG").
 		Default(DebugMessagesFakeVar).
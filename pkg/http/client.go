@@ -1,8 +1,8 @@
 package http
 
 import (
+	"crypto/tls"
 	"fmt"
-	"io"
 	"io/ioutil"
 	netHttp "net/http"
 	"os"
@@ -10,65 +10,107 @@ import (
 	"time"
 )
 
-func MakeRestAPICall(method string, url string) (content string, err error) {
-	var netClient = &netHttp.Client{
-		Timeout: time.Second * 30,
+// ClientOptions configures the client of NewClient
+type ClientOptions struct {
+	// ConnectTimeout limits dialing and TLS handshake
+	ConnectTimeout time.Duration
+	// Timeout limits the whole request including reading of the body
+	Timeout time.Duration
+	// CABundlePath is the path to PEM encoded certificates that are trusted in addition to system ones
+	CABundlePath string
+	// UserAgent is sent with every request if it is set
+	UserAgent string
+}
+
+var DefaultClientOptions = ClientOptions{
+	ConnectTimeout: 10 * time.Second,
+	Timeout:        30 * time.Second,
+}
+
+// NewClient returns the client for small requests with timeouts from options.
+// The client is built on NewTransport, the CA bundle from options is trusted in addition to the one set by SetupTLS.
+func NewClient(options ClientOptions) (*netHttp.Client, error) {
+	options = options.withDefaults()
+
+	transport := NewTransport(options.ConnectTimeout)
+
+	if options.CABundlePath != "" {
+		rootCAs, err := loadCABundle(options.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = rootCAs
 	}
 
-	response, err := netClient.Get(url)
-	if err != nil {
-		return
+	var roundTripper netHttp.RoundTripper = transport
+	if options.UserAgent != "" {
+		roundTripper = &userAgentRoundTripper{userAgent: options.UserAgent, next: transport}
 	}
-	defer response.Body.Close()
-	data, _ := ioutil.ReadAll(response.Body)
-	content = string(data)
-	return
+
+	return &netHttp.Client{
+		Transport: roundTripper,
+		Timeout:   options.Timeout,
+	}, nil
 }
 
-// DownloadLargeFile creates a dstPath/name file and write content form url
-// TODO download to tmp file, and copy after successful download. Remove all traces if error.
-// TODO Timeouts!
-// TODO progress ticks
-func DownloadLargeFile(srcUrl string, dstPath string, name string) (err error) {
-	//
-	err = os.MkdirAll(dstPath, 0755)
-	if err != nil {
-		return err
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.ConnectTimeout == 0 {
+		o.ConnectTimeout = DefaultClientOptions.ConnectTimeout
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultClientOptions.Timeout
 	}
 
-	filePath := filepath.Join(dstPath, name)
+	return o
+}
 
-	// Create the file
-	out, err := os.Create(filePath)
+type userAgentRoundTripper struct {
+	userAgent string
+	next      netHttp.RoundTripper
+}
+
+func (t *userAgentRoundTripper) RoundTrip(req *netHttp.Request) (*netHttp.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+func MakeRestAPICall(method string, url string) (content string, err error) {
+	netClient, err := NewClient(ClientOptions{Timeout: time.Second * 30})
 	if err != nil {
-		return err
+		return
 	}
-	defer out.Close()
 
-	// Get the data
-	//fmt.Printf("GET %s into %s %s\n", srcUrl, dstPath, name)
-	resp, err := netHttp.Get(srcUrl)
+	response, err := netClient.Get(url)
 	if err != nil {
-		fmt.Printf("get error: %v\n", err)
-		return err
+		return
 	}
-	defer resp.Body.Close()
+	defer response.Body.Close()
 
-	// Check server response
-	//fmt.Printf("status %v\n", resp.Status)
-	if resp.StatusCode != netHttp.StatusOK {
-		return fmt.Errorf("bad status: %v", resp.Status)
+	if response.StatusCode != netHttp.StatusOK {
+		return "", fmt.Errorf("bad status: %v", response.Status)
 	}
 
-	//fmt.Printf("start copy\n")
-	// Stream response body to a file
-	_, err = io.Copy(out, resp.Body)
+	data, _ := ioutil.ReadAll(response.Body)
+	content = string(data)
+	return
+}
+
+// DownloadLargeFile atomically creates a dstPath/name file and write content form url with the download options,
+// e.g. configured with --download-* flags
+func DownloadLargeFile(srcUrl string, dstPath string, name string, options DownloadOptions) (err error) {
+	err = os.MkdirAll(dstPath, 0755)
 	if err != nil {
-		//fmt.Printf("Copy error: %v\n", err)
 		return err
 	}
 
-	//fmt.Printf("Copy written %d bytes\n", written)
-
-	return nil
+	_, err = DownloadFile(srcUrl, filepath.Join(dstPath, name), options)
+	return err
 }
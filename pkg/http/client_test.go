@@ -0,0 +1,59 @@
+package http
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	netHttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewTLSServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		if r.URL.Path == "/stalled" {
+			time.Sleep(time.Second)
+		}
+
+		_, _ = w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	caBundlePath := filepath.Join(dir, "ca.pem")
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(caBundlePath, caBundle, 0644))
+
+	client, err := NewClient(ClientOptions{})
+	assert.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.Error(t, err, "the server certificate should not be trusted without the CA bundle")
+
+	client, err = NewClient(ClientOptions{CABundlePath: caBundlePath, UserAgent: "multiwerf/v1.0.0", Timeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		userAgent, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "multiwerf/v1.0.0", string(userAgent))
+	}
+
+	_, err = client.Get(server.URL + "/stalled")
+	assert.Error(t, err, "the request should be limited by the timeout")
+
+	_, err = NewClient(ClientOptions{CABundlePath: filepath.Join(dir, "not-found.pem")})
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(caBundlePath, []byte("not a certificate"), 0644))
+	_, err = NewClient(ClientOptions{CABundlePath: caBundlePath})
+	assert.Error(t, err)
+}
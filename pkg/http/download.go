@@ -0,0 +1,354 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	netHttp "net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/werf/multiwerf/pkg/util"
+)
+
+// DownloadOptions configures timeouts, retries and progress reporting of DownloadFile
+type DownloadOptions struct {
+	// ConnectTimeout limits dialing and TLS handshake
+	ConnectTimeout time.Duration
+	// ReadTimeout limits waiting for response headers and for every next chunk of the body
+	ReadTimeout time.Duration
+	// Retries is the number of additional attempts after a failed one
+	Retries int
+	// RetryDelay is the delay before the first retry, it is doubled for every next retry
+	RetryDelay time.Duration
+	// OnProgress is called every time a chunk of the file is written
+	OnProgress func(event ProgressEvent)
+}
+
+// ProgressEvent describes the progress of the file download
+type ProgressEvent struct {
+	Name    string
+	Written int64
+	Total   int64 // -1 if the size is unknown
+}
+
+var DefaultDownloadOptions = DownloadOptions{
+	ConnectTimeout: 30 * time.Second,
+	ReadTimeout:    60 * time.Second,
+	Retries:        5,
+	RetryDelay:     time.Second,
+}
+
+// StatusError is returned if the server responds with an unexpected status
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status: %v", e.Status)
+}
+
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
+// ResumableWriter is the destination of Download that keeps the written content between attempts
+type ResumableWriter interface {
+	io.Writer
+	// Offset returns the size of the written content
+	Offset() int64
+	// Reset discards the written content
+	Reset() error
+}
+
+// OpenFunc opens the content starting from offset.
+// It returns the body with the actual start position of the body and the total size of the content (-1 if unknown).
+// Errors with StatusCode() method are retried only for 5xx, 408 and 429 codes.
+type OpenFunc func(ctx context.Context, offset int64) (body io.ReadCloser, start int64, total int64, err error)
+
+// NewDownloadClient returns the client with connect and read timeouts from options.
+// The total request time is not limited to allow downloading of large files over slow links.
+func NewDownloadClient(options DownloadOptions) *netHttp.Client {
+	options = options.withDefaults()
+
+	transport := NewTransport(options.ConnectTimeout)
+	transport.ResponseHeaderTimeout = options.ReadTimeout
+
+	return &netHttp.Client{Transport: transport}
+}
+
+// DownloadFile atomically downloads srcUrl into filePath and returns SHA256 of the content.
+// The content is written into the partial file next to filePath, the file is synced and renamed only after successful download.
+// The partial file is kept if the download fails and the next download of the same filePath is resumed from its size.
+func DownloadFile(srcUrl string, filePath string, options DownloadOptions) (string, error) {
+	file, err := util.OpenPartialFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := Download(path.Base(srcUrl), OpenUrl(NewDownloadClient(options), srcUrl), file, options); err != nil {
+		file.Suspend()
+		return "", err
+	}
+
+	return file.Commit()
+}
+
+// OpenUrl returns OpenFunc that requests the rest of the content with the Range header
+func OpenUrl(client *netHttp.Client, srcUrl string) OpenFunc {
+	return func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+		req, err := netHttp.NewRequestWithContext(ctx, netHttp.MethodGet, srcUrl, nil)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		switch {
+		case resp.StatusCode == netHttp.StatusOK:
+			return resp.Body, 0, resp.ContentLength, nil
+		case resp.StatusCode == netHttp.StatusPartialContent && offset > 0:
+			start, size, ok := ParseContentRange(resp.Header.Get("Content-Range"))
+			if !ok {
+				resp.Body.Close()
+				return nil, 0, 0, fmt.Errorf("unexpected content range %q", resp.Header.Get("Content-Range"))
+			}
+
+			return resp.Body, start, size, nil
+		default:
+			resp.Body.Close()
+			return nil, 0, 0, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+		}
+	}
+}
+
+// Download reads the content opened with open into w.
+//
+// The content written by the previous attempts is resumed: open is called with the size of the written content
+// and the content is rewritten from scratch if the source does not support ranges.
+// The download is retried with exponential backoff on network errors, timeouts and 5xx responses.
+func Download(name string, open OpenFunc, w ResumableWriter, options DownloadOptions) error {
+	options = options.withDefaults()
+
+	delay := options.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := downloadAttempt(name, open, w, options)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= options.Retries || !isRetryableError(err) {
+			if attempt > 0 {
+				return fmt.Errorf("%s (after %d attempts)", err, attempt+1)
+			}
+
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func downloadAttempt(name string, open OpenFunc, w ResumableWriter, options DownloadOptions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	offset := w.Offset()
+	body, start, total, err := open(ctx, offset)
+	if err != nil {
+		if offset > 0 && statusCode(err) == netHttp.StatusRequestedRangeNotSatisfiable {
+			// the written content does not match the source, the next attempt starts from scratch
+			if err := w.Reset(); err != nil {
+				return err
+			}
+
+			return fmt.Errorf("resume failed: %s", err)
+		}
+
+		return err
+	}
+	defer body.Close()
+
+	if start != offset {
+		if err := w.Reset(); err != nil {
+			return err
+		}
+
+		if start != 0 {
+			return fmt.Errorf("unexpected content start %d, expected %d", start, offset)
+		}
+	}
+
+	reportProgress := func() {
+		if options.OnProgress != nil {
+			options.OnProgress(ProgressEvent{Name: name, Written: w.Offset(), Total: total})
+		}
+	}
+
+	reportProgress()
+
+	reader := newIdleTimeoutReader(body, options.ReadTimeout, cancel)
+	defer reader.stop()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := reader.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+
+			reportProgress()
+		}
+
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			if reader.timedOut() {
+				return fmt.Errorf("no data received for %s", options.ReadTimeout)
+			}
+
+			return readErr
+		}
+	}
+
+	if total >= 0 && w.Offset() != total {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func (o DownloadOptions) withDefaults() DownloadOptions {
+	if o.ConnectTimeout == 0 {
+		o.ConnectTimeout = DefaultDownloadOptions.ConnectTimeout
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = DefaultDownloadOptions.ReadTimeout
+	}
+	if o.RetryDelay == 0 {
+		o.RetryDelay = DefaultDownloadOptions.RetryDelay
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+
+	return o
+}
+
+// isRetryableError returns false for client errors that will not be fixed by retrying
+func isRetryableError(err error) bool {
+	code := statusCode(err)
+	switch {
+	case code == netHttp.StatusRequestTimeout, code == netHttp.StatusTooManyRequests:
+		return true
+	case code >= 400 && code < 500:
+		return false
+	}
+
+	return true
+}
+
+// statusCode returns the status code of StatusError or S3 request failure and 0 for other errors
+func statusCode(err error) int {
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode()
+	}
+
+	return 0
+}
+
+// IsNotFoundError returns true if the file does not exist in the repository, i.e. the server responds with 404
+func IsNotFoundError(err error) bool {
+	return statusCode(err) == netHttp.StatusNotFound
+}
+
+// ParseContentRange parses "bytes start-end/size" header, size is -1 if it is unknown
+func ParseContentRange(value string) (start int64, size int64, ok bool) {
+	value = strings.TrimPrefix(value, "bytes ")
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	rangeParts := strings.SplitN(parts[0], "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(rangeParts[0], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if parts[1] == "*" {
+		return start, -1, true
+	}
+
+	size, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, size, true
+}
+
+// idleTimeoutReader cancels the request if no data is read during the timeout
+type idleTimeoutReader struct {
+	reader  io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	expired chan struct{}
+	once    sync.Once
+}
+
+func newIdleTimeoutReader(reader io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	r := &idleTimeoutReader{
+		reader:  reader,
+		timeout: timeout,
+		expired: make(chan struct{}),
+	}
+
+	r.timer = time.AfterFunc(timeout, func() {
+		r.once.Do(func() { close(r.expired) })
+		cancel()
+	})
+
+	return r
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+
+	return n, err
+}
+
+func (r *idleTimeoutReader) timedOut() bool {
+	select {
+	case <-r.expired:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *idleTimeoutReader) stop() {
+	r.timer.Stop()
+}
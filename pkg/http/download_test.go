@@ -0,0 +1,228 @@
+package http
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	netHttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyServer serves the content and breaks the connection after every breakAfter bytes of the response body
+type flakyServer struct {
+	*httptest.Server
+
+	content    string
+	breakAfter int
+
+	mux    sync.Mutex
+	ranges []string
+}
+
+func newFlakyServer(content string, breakAfter int) *flakyServer {
+	s := &flakyServer{content: content, breakAfter: breakAfter}
+	s.Server = httptest.NewServer(netHttp.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *flakyServer) handle(w netHttp.ResponseWriter, r *netHttp.Request) {
+	s.mux.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mux.Unlock()
+
+	if r.URL.Path != "/werf" {
+		w.WriteHeader(netHttp.StatusNotFound)
+		return
+	}
+
+	if s.breakAfter == 0 {
+		netHttp.ServeContent(w, r, "werf", time.Time{}, strings.NewReader(s.content))
+		return
+	}
+
+	netHttp.ServeContent(&brokenResponseWriter{ResponseWriter: w, limit: s.breakAfter}, r, "werf", time.Time{}, strings.NewReader(s.content))
+}
+
+type brokenResponseWriter struct {
+	netHttp.ResponseWriter
+	limit   int
+	written int
+}
+
+func (w *brokenResponseWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		p = p[:w.limit-w.written]
+		n, _ := w.ResponseWriter.Write(p)
+		w.written += n
+
+		// the handler panic aborts the response
+		panic(netHttp.ErrAbortHandler)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += n
+	return n, err
+}
+
+func Test_DownloadFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	server := newFlakyServer(content, 30000)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var lastEvent ProgressEvent
+	filePath := filepath.Join(dir, "werf")
+	hash, err := DownloadFile(server.URL+"/werf", filePath, DownloadOptions{
+		Retries:    5,
+		RetryDelay: time.Millisecond,
+		OnProgress: func(event ProgressEvent) {
+			lastEvent = event
+		},
+	})
+	assert.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(content))), hash)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1, "the temporary file should be renamed")
+
+	assert.Equal(t, []string{"", "bytes=30000-", "bytes=60000-", "bytes=90000-"}, server.ranges)
+	assert.Equal(t, ProgressEvent{Name: "werf", Written: 100000, Total: 100000}, lastEvent)
+}
+
+func Test_DownloadFile_Retries(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	server := newFlakyServer(content, 30000)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = DownloadFile(server.URL+"/werf", filepath.Join(dir, "werf"), DownloadOptions{
+		Retries:    1,
+		RetryDelay: time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.Len(t, server.ranges, 2)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1, "the partial file should be kept") {
+		assert.Equal(t, "werf.partial", files[0].Name())
+		assert.EqualValues(t, 60000, files[0].Size())
+	}
+
+	server.ranges = nil
+
+	_, err = DownloadFile(server.URL+"/missing", filepath.Join(dir, "missing"), DownloadOptions{
+		Retries:    5,
+		RetryDelay: time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.Len(t, server.ranges, 1, "client errors should not be retried")
+	_, err = os.Stat(filepath.Join(dir, "missing.partial"))
+	assert.True(t, os.IsNotExist(err), "the empty partial file should be removed")
+}
+
+func Test_DownloadFile_ResumePartialFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	server := newFlakyServer(content, 30000)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filePath := filepath.Join(dir, "werf")
+	_, err = DownloadFile(server.URL+"/werf", filePath, DownloadOptions{RetryDelay: time.Millisecond})
+	assert.Error(t, err)
+
+	// the next download, e.g. by the next multiwerf run, is resumed from the size of the partial file
+	hash, err := DownloadFile(server.URL+"/werf", filePath, DownloadOptions{
+		Retries:    5,
+		RetryDelay: time.Millisecond,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(content))), hash, "the hash should include the resumed content")
+
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	_, err = os.Stat(filePath + ".partial")
+	assert.True(t, os.IsNotExist(err), "the partial file should be renamed")
+
+	assert.Equal(t, []string{"", "bytes=30000-", "bytes=60000-", "bytes=90000-"}, server.ranges)
+}
+
+func Test_DownloadFile_ReadTimeout(t *testing.T) {
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		w.Header().Set("Content-Length", "10")
+		_, _ = w.Write([]byte("01234"))
+		w.(netHttp.Flusher).Flush()
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = DownloadFile(server.URL+"/werf", filepath.Join(dir, "werf"), DownloadOptions{
+		ReadTimeout: 100 * time.Millisecond,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no data received")
+	}
+}
+
+func Test_ParseContentRange(t *testing.T) {
+	start, size, ok := ParseContentRange("bytes 100-199/200")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(200), size)
+
+	start, size, ok = ParseContentRange("bytes 100-199/*")
+	assert.True(t, ok)
+	assert.Equal(t, int64(100), start)
+	assert.Equal(t, int64(-1), size)
+
+	_, _, ok = ParseContentRange("bytes */200")
+	assert.False(t, ok)
+}
+
+func Test_DownloadLargeFile_Options(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	server := newFlakyServer(content, 30000)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = DownloadLargeFile(server.URL+"/werf", filepath.Join(dir, "bin"), "werf", DownloadOptions{
+		Retries:    0,
+		RetryDelay: time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.Len(t, server.ranges, 1, "the download should not be retried")
+}
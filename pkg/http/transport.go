@@ -0,0 +1,101 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	netHttp "net/http"
+	"time"
+)
+
+// TLSOptions configures TLS of every transport returned by NewTransport
+type TLSOptions struct {
+	// CABundlePath is the path to PEM encoded certificates that are trusted in addition to system ones
+	CABundlePath string
+	// ClientCertPath is the path to the PEM encoded client certificate for mutual TLS
+	ClientCertPath string
+	// ClientKeyPath is the path to the PEM encoded key of the client certificate
+	ClientKeyPath string
+}
+
+// tlsConfig is set by SetupTLS, the default config is used if it is nil
+var tlsConfig *tls.Config
+
+// SetupTLS loads certificates for transports returned by NewTransport.
+// It should be called before any request.
+func SetupTLS(options TLSOptions) error {
+	if options.CABundlePath == "" && options.ClientCertPath == "" && options.ClientKeyPath == "" {
+		tlsConfig = nil
+		return nil
+	}
+
+	config := &tls.Config{}
+
+	if options.CABundlePath != "" {
+		rootCAs, err := loadCABundle(options.CABundlePath)
+		if err != nil {
+			return err
+		}
+
+		config.RootCAs = rootCAs
+	}
+
+	if options.ClientCertPath != "" || options.ClientKeyPath != "" {
+		if options.ClientCertPath == "" || options.ClientKeyPath == "" {
+			return fmt.Errorf("both the client certificate and the client key should be set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(options.ClientCertPath, options.ClientKeyPath)
+		if err != nil {
+			return fmt.Errorf("unable to load client certificate %q and key %q: %s", options.ClientCertPath, options.ClientKeyPath, err)
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	tlsConfig = config
+
+	return nil
+}
+
+// NewTransport returns the transport that every multiwerf client is built on.
+// The proxy is selected by HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables and TLS is configured by SetupTLS.
+func NewTransport(connectTimeout time.Duration) *netHttp.Transport {
+	transport := &netHttp.Transport{
+		Proxy: netHttp.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+
+	return transport
+}
+
+// loadCABundle returns system certificates with certificates from the PEM file
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA bundle %q: %s", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("unable to parse CA bundle %q: no PEM encoded certificates found", path)
+	}
+
+	return pool, nil
+}
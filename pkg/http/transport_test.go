@@ -0,0 +1,97 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	netHttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupTLS(t *testing.T) {
+	defer func() { tlsConfig = nil }()
+
+	dir, err := ioutil.TempDir("", "multiwerf-http-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clientCertPath, clientKeyPath, clientCert := writeClientCertificate(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caBundlePath := filepath.Join(dir, "ca.pem")
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, ioutil.WriteFile(caBundlePath, caBundle, 0644))
+
+	get := func() error {
+		resp, err := (&netHttp.Client{Transport: NewTransport(time.Second)}).Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		return err
+	}
+
+	assert.NoError(t, SetupTLS(TLSOptions{}))
+	assert.Error(t, get(), "the server certificate should not be trusted without the CA bundle")
+
+	assert.NoError(t, SetupTLS(TLSOptions{CABundlePath: caBundlePath}))
+	assert.Error(t, get(), "the server should require the client certificate")
+
+	assert.NoError(t, SetupTLS(TLSOptions{CABundlePath: caBundlePath, ClientCertPath: clientCertPath, ClientKeyPath: clientKeyPath}))
+	assert.NoError(t, get())
+
+	assert.Error(t, SetupTLS(TLSOptions{ClientCertPath: clientCertPath}), "the client key is required")
+	assert.Error(t, SetupTLS(TLSOptions{CABundlePath: filepath.Join(dir, "not-found.pem")}))
+	assert.Error(t, SetupTLS(TLSOptions{CABundlePath: clientKeyPath}), "the CA bundle without certificates")
+}
+
+func writeClientCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "multiwerf"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	assert.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certPath, keyPath, cert
+}
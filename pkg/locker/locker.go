@@ -6,7 +6,8 @@ import (
 )
 
 var (
-	Locker lockgate.Locker
+	Locker   lockgate.Locker
+	LocksDir string
 )
 
 func Init(locksDir string) error {
@@ -15,7 +16,13 @@ func Init(locksDir string) error {
 		return err
 	} else {
 		Locker = locker
+		LocksDir = locksDir
 	}
 
 	return nil
 }
+
+// LockFilePath returns the path to the file of the lock with the name in LocksDir
+func LockFilePath(lockName string) string {
+	return file_lock.NewFileLock(lockName, LocksDir).(*file_lock.FileLock).LockFilePath()
+}
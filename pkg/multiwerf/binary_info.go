@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"regexp"
 
+	"github.com/werf/lockgate"
+
 	"github.com/werf/multiwerf/pkg/app"
 )
 
@@ -13,13 +15,25 @@ type BinaryInfo struct {
 	BinaryPath   string
 	Version      string
 	HashVerified bool
+
+	inUseLockHandle *lockgate.LockHandle
 }
 
 // verifiedLocalBinaryInfo returns BinaryInfo object for the version if it is
-// stored in StorageDir and valid. Empty object is returned if no binary found.
-// Hash of binary is verified with SHA256SUMS files.
+// stored in SystemStoreDir or StorageDir and valid. Empty object is returned if no binary found.
+// Hash of binary is verified with SHA256SUMS files and SHA256SUMS is verified with SHA256SUMS.sig
+// signature if the trusted keyring is set.
 func verifiedLocalBinaryInfo(messages chan ActionMessage, version string) (*BinaryInfo, error) {
-	dstPath := localVersionDirPath(version)
+	if binInfo := systemStoreBinaryInfo(messages, version, true); binInfo != nil {
+		return binInfo, nil
+	}
+
+	return verifiedBinaryInfo(messages, localVersionDirPath(version), version)
+}
+
+// verifiedBinaryInfo returns BinaryInfo object for the version if it is stored in the dir.
+// HashVerified is set if the binary is valid.
+func verifiedBinaryInfo(messages chan ActionMessage, dstPath string, version string) (*BinaryInfo, error) {
 	files := ReleaseFiles(app.AppPackageName, version, app.OsArch)
 	messages <- ActionMessage{
 		msg:   fmt.Sprintf("dstPath is %s, files: %+v", dstPath, files),
@@ -49,14 +63,28 @@ func verifiedLocalBinaryInfo(messages chan ActionMessage, version string) (*Bina
 		return nil, err
 	}
 
+	if match {
+		verified, err := verifyReleaseSignature(messages, dstPath, files["hash"], files["sig"])
+		if err != nil {
+			return nil, err
+		}
+
+		match = verified
+	}
+
 	binInfo.HashVerified = match
 
 	return binInfo, nil
 }
 
 // localBinaryInfo returns BinaryInfo object for the version if it is
-// stored in StorageDir. Empty object is returned if no binary found.
+// stored in SystemStoreDir and valid or it is stored in StorageDir. Empty object is returned if no binary found.
+// Files are not verified unless the system store version has not been verified yet or has been changed since.
 func localBinaryInfo(messages chan ActionMessage, version string) (*BinaryInfo, error) {
+	if binInfo := systemStoreBinaryInfo(messages, version, false); binInfo != nil {
+		return binInfo, nil
+	}
+
 	dstPath := localVersionDirPath(version)
 	files := ReleaseFiles(app.AppPackageName, version, app.OsArch)
 	messages <- ActionMessage{
@@ -78,19 +106,126 @@ func localBinaryInfo(messages chan ActionMessage, version string) (*BinaryInfo,
 	return binInfo, nil
 }
 
+// systemStoreBinaryInfo returns BinaryInfo object for the version if it is stored in SystemStoreDir and valid.
+// The system store is read-only, so the version with invalid or corrupted files is ignored and StorageDir is used instead.
+//
+// The verification result is recorded with sizes and modification times of release files.
+// If verify is false, the recorded result is used while files are not changed, otherwise files are verified again.
+func systemStoreBinaryInfo(messages chan ActionMessage, version string, verify bool) *BinaryInfo {
+	if SystemStoreDir == "" {
+		return nil
+	}
+
+	dstPath := systemStoreVersionDirPath(version)
+	if exist, err := DirExists(dstPath); err != nil || !exist {
+		return nil
+	}
+
+	// the record is taken before the verification to detect changes made during it
+	record, err := newSystemStoreRecord(version)
+	if err != nil {
+		messages <- ActionMessage{
+			msg:     fmt.Sprintf("The version %s in the system store %s is ignored: %s", version, SystemStoreDir, err),
+			msgType: WarnMsgType,
+		}
+
+		return nil
+	}
+
+	if !verify {
+		if verified, err := isSystemStoreVersionVerified(version, record); err == nil && verified {
+			messages <- ActionMessage{
+				msg:   fmt.Sprintf("The version %s is available in the system store and has been verified", version),
+				debug: true,
+			}
+
+			return &BinaryInfo{
+				BinaryPath:   filepath.Join(dstPath, ReleaseFiles(app.AppPackageName, version, app.OsArch)["program"]),
+				Version:      version,
+				HashVerified: true,
+			}
+		}
+	}
+
+	binInfo, err := verifiedBinaryInfo(messages, dstPath, version)
+	if err != nil {
+		messages <- ActionMessage{
+			msg:     fmt.Sprintf("The version %s in the system store %s is ignored: %s", version, SystemStoreDir, err),
+			msgType: WarnMsgType,
+		}
+
+		return nil
+	} else if binInfo == nil {
+		return nil
+	} else if !binInfo.HashVerified {
+		setSystemStoreVersionVerified(messages, version, nil)
+
+		messages <- ActionMessage{
+			msg:     fmt.Sprintf("The version %s in the system store %s has invalid or corrupted files and is ignored", version, SystemStoreDir),
+			msgType: WarnMsgType,
+		}
+
+		return nil
+	}
+
+	setSystemStoreVersionVerified(messages, version, &record)
+
+	messages <- ActionMessage{
+		msg:   fmt.Sprintf("The version %s is available in the system store", version),
+		debug: true,
+	}
+
+	return binInfo
+}
+
+// localVersions returns versions stored in StorageDir
 func localVersions() ([]string, error) {
+	return versionsInDir(StorageDir)
+}
+
+// availableVersions returns versions stored in SystemStoreDir and StorageDir
+func availableVersions() ([]string, error) {
+	versions, err := localVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	if SystemStoreDir == "" {
+		return versions, nil
+	}
+
+	systemStoreVersions, err := versionsInDir(SystemStoreDir)
+	if err != nil {
+		return nil, err
+	}
+
+	isLocal := map[string]bool{}
+	for _, version := range versions {
+		isLocal[version] = true
+	}
+
+	for _, version := range systemStoreVersions {
+		if !isLocal[version] {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+func versionsInDir(dir string) ([]string, error) {
 	var versions []string
 
-	exist, err := DirExists(StorageDir)
+	exist, err := DirExists(dir)
 	if err != nil {
-		return nil, fmt.Errorf("dir exists failed %s: %s", StorageDir, err)
+		return nil, fmt.Errorf("dir exists failed %s: %s", dir, err)
 	} else if !exist {
 		return []string{}, nil
 	}
 
-	files, err := ioutil.ReadDir(StorageDir)
+	files, err := ioutil.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("read dir failed %s: %s", StorageDir, err)
+		return nil, fmt.Errorf("read dir failed %s: %s", dir, err)
 	}
 
 	versionGlob, err := regexp.Compile("v[0-9]*\\.[0-9]*\\.[0-9]*.*")
@@ -114,3 +249,7 @@ func localVersions() ([]string, error) {
 func localVersionDirPath(version string) string {
 	return filepath.Join(StorageDir, version)
 }
+
+func systemStoreVersionDirPath(version string) string {
+	return filepath.Join(SystemStoreDir, version)
+}
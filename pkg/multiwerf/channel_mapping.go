@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/werf/multiwerf/pkg/app"
 )
@@ -24,13 +26,17 @@ type ChannelMapping interface {
 }
 
 type ChannelMappingBase struct {
-	Multiwerf []struct {
-		Group    string `json:"group"`
-		Channels []struct {
-			Name    string `json:"name"`
-			Version string `json:"version"`
-		} `json:"channels"`
-	} `json:"multiwerf"`
+	Multiwerf []ChannelMappingGroup `json:"multiwerf"`
+}
+
+type ChannelMappingGroup struct {
+	Group    string                  `json:"group"`
+	Channels []ChannelMappingChannel `json:"channels"`
+}
+
+type ChannelMappingChannel struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
 }
 
 func (c *ChannelMappingBase) ChannelVersion(group, channel string) (string, error) {
@@ -59,6 +65,19 @@ func (c *ChannelMappingBase) AllVersions() []string {
 	return versions
 }
 
+// VersionChannels returns group/channel pairs by versions
+func (c *ChannelMappingBase) VersionChannels() map[string][]string {
+	result := map[string][]string{}
+
+	for _, g := range c.Multiwerf {
+		for _, c := range g.Channels {
+			result[c.Version] = append(result[c.Version], fmt.Sprintf("%s/%s", g.Group, c.Name))
+		}
+	}
+
+	return result
+}
+
 func (c *ChannelMappingBase) Save() error {
 	return nil
 }
@@ -80,9 +99,28 @@ type ChannelMappingLocal struct {
 
 type ChannelMappingRemote struct {
 	ChannelMappingBase
+
+	// signedBy contains signer names if the channel mapping signature has been verified
+	signedBy []string
+	// meta contains validators of the response to make conditional requests next time
+	meta *channelMappingMeta
+	// origins contains sources of group/channel versions by keys in form GROUP/CHANNEL if overlays are used
+	origins map[string]string
 }
 
 func (c *ChannelMappingRemote) Save() error {
+	if err := c.save(); err != nil {
+		return err
+	}
+
+	if err := saveLocalChannelMappingMeta(c.meta); err != nil {
+		return fmt.Errorf("save channel mapping meta failed: %s", err)
+	}
+
+	return nil
+}
+
+func (c *ChannelMappingRemote) save() error {
 	newData, err := c.Marshal()
 
 	// compare new channel mapping and current
@@ -91,6 +129,8 @@ func (c *ChannelMappingRemote) Save() error {
 		return fmt.Errorf("file exists failed %s: %s", localChannelMappingPath(), err)
 	}
 
+	var previous *ChannelMappingBase
+	var previousSavedAt time.Time
 	if currentExist {
 		currentData, err := ioutil.ReadFile(localChannelMappingPath())
 		if err != nil {
@@ -101,6 +141,15 @@ func (c *ChannelMappingRemote) Save() error {
 			return nil
 		}
 
+		// the current channel mapping is kept in the history only if it is valid
+		currentChannelMapping := &ChannelMappingBase{}
+		if err := json.Unmarshal(currentData, currentChannelMapping); err == nil {
+			previous = currentChannelMapping
+			if fileInfo, err := os.Stat(localChannelMappingPath()); err == nil {
+				previousSavedAt = fileInfo.ModTime()
+			}
+		}
+
 		// compare new channel mapping and old
 		var oldData []byte
 		oldExist, err := FileExists(localOldChannelMappingPath())
@@ -144,16 +193,38 @@ func (c *ChannelMappingRemote) Save() error {
 
 	shouldBeDeleted = false
 
+	if err := recordChannelMappingHistory(previous, previousSavedAt, &c.ChannelMappingBase); err != nil {
+		return fmt.Errorf("record channel mapping history failed: %s", err)
+	}
+
 	return nil
 }
 
-func newRemoteChannelMapping(channelMappingUrl string) (*ChannelMappingRemote, error) {
-	resp, err := http.Get(channelMappingUrl)
+// newRemoteChannelMapping downloads the channel mapping.
+// If meta is set the request is conditional and RemoteChannelMappingNotModifiedError is returned if the channel mapping is not modified.
+func newRemoteChannelMapping(channelMappingUrl string, meta *channelMappingMeta) (*ChannelMappingRemote, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, channelMappingUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	meta.setConditionalHeaders(req)
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && meta != nil {
+		return nil, RemoteChannelMappingNotModifiedError{error: fmt.Errorf("the channel mapping is not modified")}
+	}
+
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("unexpected response status code %d", resp.StatusCode)
 	}
@@ -163,11 +234,23 @@ func newRemoteChannelMapping(channelMappingUrl string) (*ChannelMappingRemote, e
 		return nil, fmt.Errorf("respBody read failed: %s", err)
 	}
 
-	channelMapping := &ChannelMappingRemote{}
+	signedBy, err := verifyChannelMappingSignature(client, channelMappingUrl, data)
+	if err != nil {
+		return nil, err
+	}
+
+	channelMapping := &ChannelMappingRemote{
+		signedBy: signedBy,
+		meta:     newChannelMappingMeta(channelMappingUrl, resp.Header),
+	}
 	if err := json.Unmarshal(data, channelMapping); err != nil {
 		return nil, fmt.Errorf("unmarshal json failed: %s", err)
 	}
 
+	if err := channelMapping.Validate(app.ChannelMappingCheckStabilityOrder); err != nil {
+		return nil, fmt.Errorf("the channel mapping is not valid: %s", err)
+	}
+
 	return channelMapping, nil
 }
 
@@ -206,23 +289,42 @@ func isLocalChannelMappingFileExist() (bool, error) {
 
 func GetChannelMapping(messages chan ActionMessage, tryRemoteChannelMapping bool) (ChannelMapping, error) {
 	if tryRemoteChannelMapping {
-		channelMapping, err := newRemoteChannelMapping(app.ChannelMappingUrl)
-		if err != nil {
+		channelMapping, err := getRemoteChannelMapping(messages)
+		if channelMapping != nil {
+			return channelMapping, nil
+		}
+
+		switch err.(type) {
+		case RemoteChannelMappingNotModifiedError:
+			messages <- ActionMessage{
+				msg:   fmt.Sprintf("The remote channel mapping %s is not modified since the last update", app.ChannelMappingUrl),
+				debug: true,
+			}
+		default:
 			messages <- ActionMessage{
 				msg:     fmt.Sprintf("Get remote channel mapping from %s failed: %s", app.ChannelMappingUrl, err),
 				msgType: WarnMsgType,
 			}
-		}
 
-		if channelMapping != nil {
-			return channelMapping, nil
-		}
-	}
+			messages <- ActionMessage{
+				msg:     fmt.Sprintf("Trying to get the local channel mapping %s ...", localChannelMappingPath()),
+				msgType: WarnMsgType,
+			}
 
-	if tryRemoteChannelMapping {
-		messages <- ActionMessage{
-			msg:     fmt.Sprintf("Trying to get the local channel mapping %s ...", localChannelMappingPath()),
-			msgType: WarnMsgType,
+			// overlays are applied to the local channel mapping as to the remote one
+			if len(app.ChannelMappingOverlays) != 0 {
+				channelMapping, err := newLocalChannelMappingWithOverlays(localChannelMappingPath(), app.ChannelMappingOverlays)
+				if err == nil {
+					return channelMapping, nil
+				}
+
+				if _, ok := err.(LocalChannelMappingNotFoundError); !ok {
+					messages <- ActionMessage{
+						msg:     fmt.Sprintf("Apply channel mapping overlays to the local channel mapping failed: %s", err),
+						msgType: WarnMsgType,
+					}
+				}
+			}
 		}
 	}
 
@@ -234,6 +336,47 @@ func GetChannelMapping(messages chan ActionMessage, tryRemoteChannelMapping bool
 	return channelMapping, nil
 }
 
+// getRemoteChannelMapping downloads the remote channel mapping merged with overlays.
+// The request is conditional if there are no overlays and the local channel mapping has been saved from the same URL.
+func getRemoteChannelMapping(messages chan ActionMessage) (*ChannelMappingRemote, error) {
+	var meta *channelMappingMeta
+	if len(app.ChannelMappingOverlays) == 0 {
+		var err error
+		meta, err = localChannelMappingMeta(app.ChannelMappingUrl)
+		if err != nil {
+			messages <- ActionMessage{
+				msg:     fmt.Sprintf("Get channel mapping meta failed: %s", err),
+				msgType: WarnMsgType,
+			}
+		}
+	}
+
+	channelMapping, err := newRemoteChannelMappingWithOverlays(app.ChannelMappingUrl, app.ChannelMappingOverlays, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range channelMapping.Multiwerf {
+		for _, c := range g.Channels {
+			if origin, ok := channelMapping.origins[fmt.Sprintf("%s/%s", g.Group, c.Name)]; ok {
+				messages <- ActionMessage{
+					msg:   fmt.Sprintf("The version %s for channel %s/%s comes from %s", c.Version, g.Group, c.Name, origin),
+					debug: true,
+				}
+			}
+		}
+	}
+
+	if len(channelMapping.signedBy) != 0 {
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("The channel mapping is signed by %s", strings.Join(channelMapping.signedBy, ", ")),
+			debug: true,
+		}
+	}
+
+	return channelMapping, nil
+}
+
 func localChannelMappingPath() string {
 	if app.ChannelMappingPath != "" {
 		return app.ChannelMappingPath
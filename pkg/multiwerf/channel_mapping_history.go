@@ -0,0 +1,197 @@
+package multiwerf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+const channelMappingHistoryLockName = "channel_mapping_history"
+
+// ChannelMappingHistoryEntry is the channel mapping that became the local one at SavedAt
+type ChannelMappingHistoryEntry struct {
+	ID             int                 `json:"-"`
+	SavedAt        time.Time           `json:"saved_at"`
+	ChannelMapping *ChannelMappingBase `json:"channel_mapping"`
+}
+
+// localChannelMappingHistoryDir returns the directory with entries named <ID>.json, IDs are increasing
+func localChannelMappingHistoryDir() string {
+	return localChannelMappingPath() + ".history"
+}
+
+// recordChannelMappingHistory adds the new local channel mapping to the history and removes the oldest entries over the limit.
+// The previous local channel mapping is recorded first if the history is empty.
+func recordChannelMappingHistory(previous *ChannelMappingBase, previousSavedAt time.Time, current *ChannelMappingBase) error {
+	return lockgate.WithAcquire(locker.Locker, channelMappingHistoryLockName, lockgate.AcquireOptions{}, func(_ bool) error {
+		historyDir := localChannelMappingHistoryDir()
+		if err := os.MkdirAll(historyDir, os.ModePerm); err != nil {
+			return fmt.Errorf("mkdir all failed %s: %s", historyDir, err)
+		}
+
+		ids, err := channelMappingHistoryIDs()
+		if err != nil {
+			return err
+		}
+
+		lastID := 0
+		if len(ids) != 0 {
+			lastID = ids[len(ids)-1]
+		} else if previous != nil {
+			lastID++
+			if err := writeChannelMappingHistoryEntry(&ChannelMappingHistoryEntry{ID: lastID, SavedAt: previousSavedAt, ChannelMapping: previous}); err != nil {
+				return err
+			}
+
+			ids = append(ids, lastID)
+		}
+
+		lastID++
+		if err := writeChannelMappingHistoryEntry(&ChannelMappingHistoryEntry{ID: lastID, SavedAt: time.Now(), ChannelMapping: current}); err != nil {
+			return err
+		}
+
+		ids = append(ids, lastID)
+
+		limit := app.ChannelMappingHistoryLimit
+		if limit < 1 {
+			limit = 1
+		}
+
+		for len(ids) > limit {
+			entryPath := channelMappingHistoryEntryPath(ids[0])
+			if err := os.Remove(entryPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove file failed %s: %s", entryPath, err)
+			}
+
+			ids = ids[1:]
+		}
+
+		return nil
+	})
+}
+
+// ChannelMappingHistory returns history entries ordered from the oldest to the newest
+func ChannelMappingHistory() ([]*ChannelMappingHistoryEntry, error) {
+	ids, err := channelMappingHistoryIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*ChannelMappingHistoryEntry
+	for _, id := range ids {
+		entry, err := readChannelMappingHistoryEntry(id)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func channelMappingHistoryIDs() ([]int, error) {
+	historyDir := localChannelMappingHistoryDir()
+	exist, err := DirExists(historyDir)
+	if err != nil {
+		return nil, fmt.Errorf("dir exists failed %s: %s", historyDir, err)
+	} else if !exist {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(historyDir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir failed %s: %s", historyDir, err)
+	}
+
+	var ids []int
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+func channelMappingHistoryEntryPath(id int) string {
+	return filepath.Join(localChannelMappingHistoryDir(), fmt.Sprintf("%d.json", id))
+}
+
+func readChannelMappingHistoryEntry(id int) (*ChannelMappingHistoryEntry, error) {
+	entryPath := channelMappingHistoryEntryPath(id)
+	data, err := ioutil.ReadFile(entryPath)
+	if err != nil {
+		return nil, fmt.Errorf("read file failed %s: %s", entryPath, err)
+	}
+
+	entry := &ChannelMappingHistoryEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("unmarshal json failed %s: %s", entryPath, err)
+	}
+
+	entry.ID = id
+	if entry.ChannelMapping == nil {
+		entry.ChannelMapping = &ChannelMappingBase{}
+	}
+
+	return entry, nil
+}
+
+func writeChannelMappingHistoryEntry(entry *ChannelMappingHistoryEntry) error {
+	data, err := json.MarshalIndent(entry, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(localChannelMappingHistoryDir(), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("create tmp file failed: %s", err)
+	}
+
+	shouldBeDeleted := true
+	defer func() {
+		if shouldBeDeleted {
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
+
+	if _, err := tmpFile.Write(append(data, '\n')); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("write to tmp file failed %s: %s", tmpFile.Name(), err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("close tmp file failed %s: %s", tmpFile.Name(), err)
+	}
+
+	entryPath := channelMappingHistoryEntryPath(entry.ID)
+	if err := os.Rename(tmpFile.Name(), entryPath); err != nil {
+		return fmt.Errorf("rename failed %s: %s", entryPath, err)
+	}
+
+	shouldBeDeleted = false
+
+	return nil
+}
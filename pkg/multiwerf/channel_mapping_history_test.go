@@ -0,0 +1,75 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+func Test_recordChannelMappingHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-history-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir string, limit int) {
+		StorageDir = storageDir
+		app.ChannelMappingHistoryLimit = limit
+	}(StorageDir, app.ChannelMappingHistoryLimit)
+
+	StorageDir = dir
+	app.ChannelMappingHistoryLimit = 3
+	assert.NoError(t, locker.Init(filepath.Join(dir, "locks")))
+
+	newChannelMapping := func(version string) *ChannelMappingBase {
+		return parseChannelMapping(t, `{"multiwerf": [{"group": "1.1", "channels": [{"name": "stable", "version": "`+version+`"}]}]}`)
+	}
+
+	previousSavedAt := time.Now().Add(-time.Hour).Round(time.Second)
+	assert.NoError(t, recordChannelMappingHistory(newChannelMapping("v1.1.0"), previousSavedAt, newChannelMapping("v1.1.1")))
+
+	entries, err := ChannelMappingHistory()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2, "the previous channel mapping should be recorded into the empty history") {
+		assert.Equal(t, 1, entries[0].ID)
+		assert.True(t, previousSavedAt.Equal(entries[0].SavedAt))
+		assert.Equal(t, newChannelMapping("v1.1.0"), entries[0].ChannelMapping)
+		assert.Equal(t, 2, entries[1].ID)
+		assert.Equal(t, newChannelMapping("v1.1.1"), entries[1].ChannelMapping)
+	}
+
+	for _, version := range []string{"v1.1.2", "v1.1.3"} {
+		assert.NoError(t, recordChannelMappingHistory(nil, time.Time{}, newChannelMapping(version)))
+	}
+
+	entries, err = ChannelMappingHistory()
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3, "the oldest entries over the limit should be removed") {
+		for ind, version := range []string{"v1.1.1", "v1.1.2", "v1.1.3"} {
+			assert.Equal(t, ind+2, entries[ind].ID)
+			assert.Equal(t, newChannelMapping(version), entries[ind].ChannelMapping)
+		}
+	}
+
+	from, to, err := channelMappingHistoryDiffEntries(entries, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, from.ID)
+	assert.Equal(t, 4, to.ID)
+
+	from, to, err = channelMappingHistoryDiffEntries(entries, 2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, from.ID)
+	assert.Equal(t, 3, to.ID)
+
+	_, _, err = channelMappingHistoryDiffEntries(entries, 1, 0)
+	assert.Error(t, err)
+
+	_, _, err = channelMappingHistoryDiffEntries(entries[:1], 0, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package multiwerf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/werf/multiwerf/pkg/app"
+)
+
+// RemoteChannelMappingNotModifiedError is returned if the remote channel mapping is the same as the local one
+type RemoteChannelMappingNotModifiedError struct {
+	error
+}
+
+// channelMappingMeta keeps validators of the remote channel mapping that the local one has been saved from
+// and the signature requirement that it has been verified with
+type channelMappingMeta struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	TrustedKeyring   string `json:"trusted_keyring,omitempty"`
+	RequireSignature bool   `json:"require_signature,omitempty"`
+}
+
+func localChannelMappingMetaPath() string {
+	return localChannelMappingPath() + ".meta"
+}
+
+// newChannelMappingMeta returns nil if the response has no validators
+func newChannelMappingMeta(channelMappingUrl string, header http.Header) *channelMappingMeta {
+	meta := &channelMappingMeta{
+		Url:          channelMappingUrl,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+
+		TrustedKeyring:   app.TrustedKeyringPath,
+		RequireSignature: app.RequireChannelMappingSignature,
+	}
+
+	if meta.ETag == "" && meta.LastModified == "" {
+		return nil
+	}
+
+	return meta
+}
+
+// localChannelMappingMeta returns validators to send with the request of the remote channel mapping.
+// Nil is returned if the local channel mapping does not exist, it has been saved from another URL
+// or it has been verified with another signature requirement, so the not modified response cannot skip the verification.
+func localChannelMappingMeta(channelMappingUrl string) (*channelMappingMeta, error) {
+	if exist, err := FileExists(localChannelMappingPath()); err != nil {
+		return nil, fmt.Errorf("file exists failed %s: %s", localChannelMappingPath(), err)
+	} else if !exist {
+		return nil, nil
+	}
+
+	metaPath := localChannelMappingMetaPath()
+	if exist, err := FileExists(metaPath); err != nil {
+		return nil, fmt.Errorf("file exists failed %s: %s", metaPath, err)
+	} else if !exist {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(metaPath)
+	if err != nil {
+		return nil, fmt.Errorf("read file failed %s: %s", metaPath, err)
+	}
+
+	meta := &channelMappingMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("unmarshal json failed %s: %s", metaPath, err)
+	}
+
+	if meta.Url != channelMappingUrl {
+		return nil, nil
+	}
+
+	if meta.TrustedKeyring != app.TrustedKeyringPath || meta.RequireSignature != app.RequireChannelMappingSignature {
+		return nil, nil
+	}
+
+	return meta, nil
+}
+
+// saveLocalChannelMappingMeta writes validators of the saved channel mapping or removes stale ones if meta is nil
+func saveLocalChannelMappingMeta(meta *channelMappingMeta) error {
+	metaPath := localChannelMappingMetaPath()
+
+	if meta == nil {
+		if err := os.Remove(metaPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove file failed %s: %s", metaPath, err)
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(meta, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(metaPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write file failed %s: %s", metaPath, err)
+	}
+
+	return nil
+}
+
+func (m *channelMappingMeta) setConditionalHeaders(req *http.Request) {
+	if m == nil {
+		return
+	}
+
+	if m.ETag != "" {
+		req.Header.Set("If-None-Match", m.ETag)
+	}
+
+	if m.LastModified != "" {
+		req.Header.Set("If-Modified-Since", m.LastModified)
+	}
+}
@@ -0,0 +1,74 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/app"
+)
+
+func Test_localChannelMappingMeta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-meta-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir string) { StorageDir = storageDir }(StorageDir)
+	StorageDir = dir
+
+	const url = "https://example.com/multiwerf.json"
+
+	header := http.Header{}
+	assert.Nil(t, newChannelMappingMeta(url, header), "the response without validators")
+
+	header.Set("ETag", `"v1"`)
+	header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	meta := newChannelMappingMeta(url, header)
+	assert.NoError(t, saveLocalChannelMappingMeta(meta))
+
+	loaded, err := localChannelMappingMeta(url)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "the meta without the local channel mapping")
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, DefaultLocalChannelMappingFilename), []byte("{}"), 0644))
+
+	loaded, err = localChannelMappingMeta(url)
+	assert.NoError(t, err)
+	assert.Equal(t, meta, loaded)
+
+	loaded, err = localChannelMappingMeta("https://mirror.example.com/multiwerf.json")
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "the meta of another URL")
+
+	defer func(trustedKeyringPath string, requireSignature bool) {
+		app.TrustedKeyringPath = trustedKeyringPath
+		app.RequireChannelMappingSignature = requireSignature
+	}(app.TrustedKeyringPath, app.RequireChannelMappingSignature)
+
+	app.RequireChannelMappingSignature = true
+	loaded, err = localChannelMappingMeta(url)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "the meta of the channel mapping verified without the signature requirement")
+
+	app.RequireChannelMappingSignature = false
+	app.TrustedKeyringPath = filepath.Join(dir, "keyring.gpg")
+	loaded, err = localChannelMappingMeta(url)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "the meta of the channel mapping verified with another keyring")
+	app.TrustedKeyringPath = meta.TrustedKeyring
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	meta.setConditionalHeaders(req)
+	assert.Equal(t, `"v1"`, req.Header.Get("If-None-Match"))
+	assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 GMT", req.Header.Get("If-Modified-Since"))
+
+	assert.NoError(t, saveLocalChannelMappingMeta(nil))
+	loaded, err = localChannelMappingMeta(url)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "the removed meta")
+}
@@ -0,0 +1,148 @@
+package multiwerf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/werf/multiwerf/pkg/app"
+)
+
+// channelMappingSource is the channel mapping with the URL or the path it has been read from
+type channelMappingSource struct {
+	Source         string
+	ChannelMapping *ChannelMappingBase
+}
+
+// newRemoteChannelMappingWithOverlays downloads the remote channel mapping and overrides its versions by overlays in order.
+// The merged channel mapping is validated as a whole.
+func newRemoteChannelMappingWithOverlays(channelMappingUrl string, overlays []string, meta *channelMappingMeta) (*ChannelMappingRemote, error) {
+	channelMapping, err := newRemoteChannelMapping(channelMappingUrl, meta)
+	if err != nil || len(overlays) == 0 {
+		return channelMapping, err
+	}
+
+	merged, origins, err := applyChannelMappingOverlays(channelMappingUrl, &channelMapping.ChannelMappingBase, overlays)
+	if err != nil {
+		return nil, err
+	}
+
+	channelMapping.ChannelMappingBase = *merged
+	channelMapping.origins = origins
+	// validators of the remote channel mapping do not cover overlays
+	channelMapping.meta = nil
+
+	return channelMapping, nil
+}
+
+// newLocalChannelMappingWithOverlays reads the local channel mapping and overrides its versions by overlays in order.
+// It is used if the remote channel mapping is not available, so overlays are applied the same way.
+func newLocalChannelMappingWithOverlays(channelMappingPath string, overlays []string) (*ChannelMappingLocal, error) {
+	channelMapping, err := newLocalChannelMapping(channelMappingPath)
+	if err != nil || len(overlays) == 0 {
+		return channelMapping, err
+	}
+
+	merged, _, err := applyChannelMappingOverlays(channelMappingPath, &channelMapping.ChannelMappingBase, overlays)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChannelMappingLocal{ChannelMappingBase: *merged}, nil
+}
+
+// applyChannelMappingOverlays returns the channel mapping merged with overlays and sources of group/channel versions.
+// The merged channel mapping is validated as a whole.
+func applyChannelMappingOverlays(source string, channelMapping *ChannelMappingBase, overlays []string) (*ChannelMappingBase, map[string]string, error) {
+	sources := []*channelMappingSource{{Source: source, ChannelMapping: channelMapping}}
+	for _, overlay := range overlays {
+		overlayChannelMapping, err := readChannelMappingOverlay(overlay)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get the channel mapping overlay %s failed: %s", overlay, err)
+		}
+
+		sources = append(sources, &channelMappingSource{Source: overlay, ChannelMapping: overlayChannelMapping})
+	}
+
+	merged, origins := mergeChannelMappings(sources)
+	if err := merged.Validate(app.ChannelMappingCheckStabilityOrder); err != nil {
+		return nil, nil, fmt.Errorf("the channel mapping merged with overlays is not valid: %s", err)
+	}
+
+	return merged, origins, nil
+}
+
+// readChannelMappingOverlay downloads the overlay if it is the URL and reads the local file otherwise
+func readChannelMappingOverlay(overlay string) (*ChannelMappingBase, error) {
+	if strings.HasPrefix(overlay, "http://") || strings.HasPrefix(overlay, "https://") {
+		channelMapping, err := newRemoteChannelMapping(overlay, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return &channelMapping.ChannelMappingBase, nil
+	}
+
+	path, err := ExpandPath(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %s", overlay, err)
+	}
+
+	channelMapping, err := newLocalChannelMapping(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := channelMapping.Validate(false); err != nil {
+		return nil, fmt.Errorf("the channel mapping is not valid: %s", err)
+	}
+
+	return &channelMapping.ChannelMappingBase, nil
+}
+
+// mergeChannelMappings overrides group/channel versions of the first source by the next ones.
+// Groups and channels missing in previous sources are added.
+// The source of each group/channel is returned by keys in form GROUP/CHANNEL.
+func mergeChannelMappings(sources []*channelMappingSource) (*ChannelMappingBase, map[string]string) {
+	merged := &ChannelMappingBase{}
+	origins := map[string]string{}
+
+	for _, source := range sources {
+		for _, g := range source.ChannelMapping.Multiwerf {
+			groupInd := -1
+			for ind := range merged.Multiwerf {
+				if merged.Multiwerf[ind].Group == g.Group {
+					groupInd = ind
+					break
+				}
+			}
+
+			if groupInd == -1 {
+				merged.Multiwerf = append(merged.Multiwerf, ChannelMappingGroup{Group: g.Group})
+				groupInd = len(merged.Multiwerf) - 1
+			}
+
+			group := &merged.Multiwerf[groupInd]
+			for _, c := range g.Channels {
+				channelInd := -1
+				for ind := range group.Channels {
+					if NormalizeChannel(group.Channels[ind].Name) == NormalizeChannel(c.Name) {
+						channelInd = ind
+						break
+					}
+				}
+
+				if channelInd == -1 {
+					group.Channels = append(group.Channels, c)
+				} else {
+					// the legacy channel name is replaced too
+					delete(origins, fmt.Sprintf("%s/%s", g.Group, group.Channels[channelInd].Name))
+					group.Channels[channelInd] = c
+				}
+
+				origins[fmt.Sprintf("%s/%s", g.Group, c.Name)] = source.Source
+			}
+		}
+	}
+
+	return merged, origins
+}
@@ -0,0 +1,47 @@
+package multiwerf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mergeChannelMappings(t *testing.T) {
+	upstream := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}, {"name": "stable", "version": "v1.1.1"}]},
+		{"group": "1.2", "channels": [{"name": "alpha", "version": "v1.2.5"}, {"name": "rc", "version": "v1.2.4"}, {"name": "stable", "version": "v1.2.3"}]}
+	]}`)
+	internal := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.2", "channels": [{"name": "ea", "version": "v1.2.3"}, {"name": "stable", "version": "v1.2.2"}]}
+	]}`)
+	hotfix := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.2", "channels": [{"name": "stable", "version": "v1.2.2+hotfix.1"}]},
+		{"group": "1.3", "channels": [{"name": "alpha", "version": "v1.3.0-alpha.1"}]}
+	]}`)
+
+	merged, origins := mergeChannelMappings([]*channelMappingSource{
+		{Source: "https://example.com/multiwerf.json", ChannelMapping: upstream},
+		{Source: "/etc/multiwerf/internal.json", ChannelMapping: internal},
+		{Source: "/etc/multiwerf/hotfix.json", ChannelMapping: hotfix},
+	})
+
+	assert.Equal(t, parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}, {"name": "stable", "version": "v1.1.1"}]},
+		{"group": "1.2", "channels": [{"name": "alpha", "version": "v1.2.5"}, {"name": "ea", "version": "v1.2.3"}, {"name": "stable", "version": "v1.2.2+hotfix.1"}]},
+		{"group": "1.3", "channels": [{"name": "alpha", "version": "v1.3.0-alpha.1"}]}
+	]}`), merged)
+
+	assert.Equal(t, map[string]string{
+		"1.1/alpha":  "https://example.com/multiwerf.json",
+		"1.1/stable": "https://example.com/multiwerf.json",
+		"1.2/alpha":  "https://example.com/multiwerf.json",
+		"1.2/ea":     "/etc/multiwerf/internal.json",
+		"1.2/stable": "/etc/multiwerf/hotfix.json",
+		"1.3/alpha":  "/etc/multiwerf/hotfix.json",
+	}, origins)
+
+	assert.NoError(t, merged.Validate(true))
+
+	// sources are not modified
+	assert.Equal(t, "v1.2.3", upstream.Multiwerf[1].Channels[2].Version)
+}
@@ -0,0 +1,99 @@
+package multiwerf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// Validate checks that groups are in form MAJOR.MINOR, channels are known, versions are valid semver versions
+// and there are no duplicated groups or channels.
+// If checkStabilityOrder is set the version of the more stable channel must not be greater than the version of the less stable one
+// (rock-solid ≤ stable ≤ ea ≤ beta ≤ alpha).
+func (c *ChannelMappingBase) Validate(checkStabilityOrder bool) error {
+	if len(c.Multiwerf) == 0 {
+		return fmt.Errorf("no groups defined")
+	}
+
+	var errors []string
+	groups := map[string]bool{}
+	for _, g := range c.Multiwerf {
+		if err := CheckMajorMinor(g.Group); err != nil {
+			errors = append(errors, fmt.Sprintf("group %q: %s", g.Group, err))
+			continue
+		}
+
+		if groups[g.Group] {
+			errors = append(errors, fmt.Sprintf("group %q: duplicated", g.Group))
+			continue
+		}
+		groups[g.Group] = true
+
+		if len(g.Channels) == 0 {
+			errors = append(errors, fmt.Sprintf("group %q: no channels defined", g.Group))
+			continue
+		}
+
+		channelVersions := map[string]*semver.Version{}
+		for _, ch := range g.Channels {
+			channel := NormalizeChannel(ch.Name)
+			if channelIndex(channel) == len(Channels) {
+				errors = append(errors, fmt.Sprintf("%s/%s: unknown channel, expected one of %s", g.Group, ch.Name, strings.Join(Channels, "|")))
+				continue
+			}
+
+			if _, ok := channelVersions[channel]; ok {
+				errors = append(errors, fmt.Sprintf("%s/%s: duplicated", g.Group, ch.Name))
+				continue
+			}
+
+			if ch.Version == "" {
+				errors = append(errors, fmt.Sprintf("%s/%s: empty version", g.Group, ch.Name))
+				continue
+			}
+
+			version, err := semver.NewVersion(ch.Version)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s/%s: version %q is not valid: %s", g.Group, ch.Name, ch.Version, err))
+				continue
+			}
+
+			channelVersions[channel] = version
+		}
+
+		if checkStabilityOrder {
+			errors = append(errors, checkChannelsStabilityOrder(g.Group, channelVersions)...)
+		}
+	}
+
+	if len(errors) != 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+
+	return nil
+}
+
+// checkChannelsStabilityOrder compares versions of each pair of neighbour channels of the group skipping missing ones
+func checkChannelsStabilityOrder(group string, channelVersions map[string]*semver.Version) []string {
+	var errors []string
+	var lessStableChannel string
+
+	for _, channel := range Channels {
+		version, ok := channelVersions[channel]
+		if !ok {
+			continue
+		}
+
+		if lessStableChannel != "" && version.GreaterThan(channelVersions[lessStableChannel]) {
+			errors = append(errors, fmt.Sprintf(
+				"%s/%s: version %s is greater than %s/%s version %s",
+				group, channel, version.Original(), group, lessStableChannel, channelVersions[lessStableChannel].Original(),
+			))
+		}
+
+		lessStableChannel = channel
+	}
+
+	return errors
+}
@@ -0,0 +1,42 @@
+package multiwerf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ChannelMappingBase_Validate(t *testing.T) {
+	channelMapping := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}, {"name": "rc", "version": "v1.1.2"}, {"name": "stable", "version": "v1.1.1"}]},
+		{"group": "1.2", "channels": [{"name": "alpha", "version": "v1.2.0-alpha.1"}]}
+	]}`)
+	assert.NoError(t, channelMapping.Validate(true))
+
+	for _, data := range []string{
+		`{}`,
+		`{"multiwerf": [{"group": "1", "channels": [{"name": "alpha", "version": "v1.1.3"}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": []}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}]}, {"group": "1.1", "channels": [{"name": "beta", "version": "v1.1.3"}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "nightly", "version": "v1.1.3"}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}, {"name": "alpha", "version": "v1.1.4"}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "ea", "version": "v1.1.3"}, {"name": "rc", "version": "v1.1.3"}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "alpha", "version": ""}]}]}`,
+		`{"multiwerf": [{"group": "1.1", "channels": [{"name": "alpha", "version": "latest"}]}]}`,
+	} {
+		assert.Error(t, parseChannelMapping(t, data).Validate(false), data)
+	}
+}
+
+func Test_ChannelMappingBase_Validate_StabilityOrder(t *testing.T) {
+	channelMapping := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.3"}, {"name": "beta", "version": "v1.1.4"}, {"name": "rock-solid", "version": "v1.1.1"}]}
+	]}`)
+
+	assert.NoError(t, channelMapping.Validate(false))
+
+	err := channelMapping.Validate(true)
+	if assert.Error(t, err) {
+		assert.Equal(t, "1.1/beta: version v1.1.4 is greater than 1.1/alpha version v1.1.3", err.Error())
+	}
+}
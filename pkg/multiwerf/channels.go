@@ -0,0 +1,397 @@
+package multiwerf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/output"
+)
+
+// Channels are ordered from the least to the most stable
+var Channels = []string{
+	"alpha",
+	"beta",
+	"ea",
+	"stable",
+	"rock-solid",
+}
+
+const (
+	AddedChannelDiff   = "added"
+	RemovedChannelDiff = "removed"
+	ChangedChannelDiff = "changed"
+)
+
+type ChannelsOptions struct {
+	// Group limits the output to the single MAJOR.MINOR group
+	Group string
+	// Remote enables comparison of the local channel mapping with the remote one
+	Remote bool
+}
+
+type channelMatrixRow struct {
+	Group         string
+	Channel       string
+	LocalVersion  string
+	RemoteVersion string
+	Diff          string
+}
+
+// PrintChannels prints the group/channel matrix of the local channel mapping and optionally compares it with the remote one
+func PrintChannels(options ChannelsOptions) error {
+	if options.Group != "" {
+		if err := CheckMajorMinor(options.Group); err != nil {
+			return fmt.Errorf("the group %s is not valid: %s", options.Group, err)
+		}
+	}
+
+	if err := SetupStorageDir(output.NewSilentPrint()); err != nil {
+		return err
+	}
+
+	var localChannelMapping, remoteChannelMapping *ChannelMappingBase
+
+	if channelMapping, err := newLocalChannelMapping(localChannelMappingPath()); err != nil {
+		switch err.(type) {
+		case LocalChannelMappingNotFoundError:
+			if !options.Remote {
+				return fmt.Errorf("get the local channel mapping failed: %s\nRun command `multiwerf update` to download the actual one or use --remote option", err)
+			}
+		default:
+			return fmt.Errorf("get the local channel mapping failed: %s", err)
+		}
+	} else {
+		localChannelMapping = &channelMapping.ChannelMappingBase
+	}
+
+	if options.Remote {
+		channelMapping, err := newRemoteChannelMappingWithOverlays(app.ChannelMappingUrl, app.ChannelMappingOverlays, nil)
+		if err != nil {
+			return fmt.Errorf("get remote channel mapping from %s failed: %s", app.ChannelMappingUrl, err)
+		}
+
+		remoteChannelMapping = &channelMapping.ChannelMappingBase
+	}
+
+	versions, err := availableVersions()
+	if err != nil {
+		return err
+	}
+
+	installedVersions := map[string]bool{}
+	for _, version := range versions {
+		installedVersions[version] = true
+	}
+
+	rows := channelMatrix(localChannelMapping, remoteChannelMapping, options.Group)
+
+	return printChannelMatrix(os.Stdout, rows, options.Remote, installedVersions)
+}
+
+// channelMatrix returns rows of the local and the remote channel mappings ordered by groups and channels.
+// The remote channel mapping is optional.
+func channelMatrix(local, remote *ChannelMappingBase, group string) []*channelMatrixRow {
+	var rows []*channelMatrixRow
+	rowByKey := map[string]*channelMatrixRow{}
+
+	addChannelMapping := func(channelMapping *ChannelMappingBase, setVersion func(row *channelMatrixRow, version string)) {
+		if channelMapping == nil {
+			return
+		}
+
+		for _, g := range channelMapping.Multiwerf {
+			if group != "" && g.Group != group {
+				continue
+			}
+
+			for _, c := range g.Channels {
+				key := g.Group + "/" + c.Name
+				row, ok := rowByKey[key]
+				if !ok {
+					row = &channelMatrixRow{Group: g.Group, Channel: c.Name}
+					rowByKey[key] = row
+					rows = append(rows, row)
+				}
+
+				setVersion(row, c.Version)
+			}
+		}
+	}
+
+	addChannelMapping(local, func(row *channelMatrixRow, version string) { row.LocalVersion = version })
+	addChannelMapping(remote, func(row *channelMatrixRow, version string) { row.RemoteVersion = version })
+
+	if remote != nil {
+		for _, row := range rows {
+			switch {
+			case row.LocalVersion == "":
+				row.Diff = AddedChannelDiff
+			case row.RemoteVersion == "":
+				row.Diff = RemovedChannelDiff
+			case row.LocalVersion != row.RemoteVersion:
+				row.Diff = ChangedChannelDiff
+			}
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Group != rows[j].Group {
+			return groupLess(rows[i].Group, rows[j].Group)
+		}
+
+		return channelIndex(rows[i].Channel) < channelIndex(rows[j].Channel)
+	})
+
+	return rows
+}
+
+func groupLess(a, b string) bool {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+
+	return va.LessThan(vb)
+}
+
+// channelIndex returns the position of the channel in Channels, unknown channels go last
+func channelIndex(channel string) int {
+	for ind, c := range Channels {
+		if c == channel {
+			return ind
+		}
+	}
+
+	return len(Channels)
+}
+
+// printChannelMatrix prints groups as rows and channels as columns.
+// With the remote channel mapping the changed cell is printed as LOCAL -> REMOTE.
+func printChannelMatrix(w io.Writer, rows []*channelMatrixRow, withRemote bool, installedVersions map[string]bool) error {
+	formatVersion := func(version string) string {
+		switch {
+		case version == "":
+			return "-"
+		case installedVersions[version]:
+			return version + " *"
+		default:
+			return version
+		}
+	}
+
+	formatCell := func(row *channelMatrixRow) string {
+		if row == nil {
+			return "-"
+		}
+
+		if withRemote && row.Diff != "" {
+			return formatVersion(row.LocalVersion) + " -> " + formatVersion(row.RemoteVersion)
+		}
+
+		return formatVersion(row.LocalVersion)
+	}
+
+	var groups, channels []string
+	cells := map[string]map[string]*channelMatrixRow{}
+	for _, row := range rows {
+		if _, ok := cells[row.Group]; !ok {
+			cells[row.Group] = map[string]*channelMatrixRow{}
+			groups = append(groups, row.Group)
+		}
+
+		cells[row.Group][row.Channel] = row
+
+		if !containsString(channels, row.Channel) {
+			channels = append(channels, row.Channel)
+		}
+	}
+
+	sort.SliceStable(channels, func(i, j int) bool {
+		if channelIndex(channels[i]) != channelIndex(channels[j]) {
+			return channelIndex(channels[i]) < channelIndex(channels[j])
+		}
+
+		return channels[i] < channels[j]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	_, _ = fmt.Fprintf(tw, "GROUP\t%s\n", strings.ToUpper(strings.Join(channels, "\t")))
+	for _, group := range groups {
+		line := group
+		for _, channel := range channels {
+			line += "\t" + formatCell(cells[group][channel])
+		}
+
+		_, _ = fmt.Fprintln(tw, line)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	legend := "\n* installed locally"
+	if withRemote {
+		legend += "\nLOCAL -> REMOTE the channel is changed in the remote channel mapping"
+	}
+
+	_, err := fmt.Fprintln(w, legend)
+	return err
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ChannelsHistoryOptions struct {
+	// Group limits the output to the single MAJOR.MINOR group
+	Group string
+}
+
+type ChannelsDiffOptions struct {
+	// Group limits the output to the single MAJOR.MINOR group
+	Group string
+	// From is the history entry ID to compare, the entry before To by default
+	From int
+	// To is the history entry ID to compare with, the latest entry by default
+	To int
+}
+
+// PrintChannelsHistory prints channel changes of every channel mapping history entry
+func PrintChannelsHistory(options ChannelsHistoryOptions) error {
+	entries, err := channelMappingHistory(options.Group)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tSAVED AT\tCHANNEL\tFROM\tTO")
+
+	var previous *ChannelMappingBase
+	for _, entry := range entries {
+		for _, row := range channelMatrix(previous, entry.ChannelMapping, options.Group) {
+			if row.Diff == "" {
+				continue
+			}
+
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s/%s\t%s\t%s\n", entry.ID, formatSavedAt(entry.SavedAt), row.Group, row.Channel, versionOrDash(row.LocalVersion), versionOrDash(row.RemoteVersion))
+		}
+
+		previous = entry.ChannelMapping
+	}
+
+	return tw.Flush()
+}
+
+// PrintChannelsDiff prints channel changes between two channel mapping history entries
+func PrintChannelsDiff(options ChannelsDiffOptions) error {
+	entries, err := channelMappingHistory(options.Group)
+	if err != nil {
+		return err
+	}
+
+	from, to, err := channelMappingHistoryDiffEntries(entries, options.From, options.To)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Changes from #%d (%s) to #%d (%s):\n\n", from.ID, formatSavedAt(from.SavedAt), to.ID, formatSavedAt(to.SavedAt))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHANNEL\tFROM\tTO\tDIFF")
+
+	for _, row := range channelMatrix(from.ChannelMapping, to.ChannelMapping, options.Group) {
+		if row.Diff == "" {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s/%s\t%s\t%s\t%s\n", row.Group, row.Channel, versionOrDash(row.LocalVersion), versionOrDash(row.RemoteVersion), row.Diff)
+	}
+
+	return tw.Flush()
+}
+
+func channelMappingHistory(group string) ([]*ChannelMappingHistoryEntry, error) {
+	if group != "" {
+		if err := CheckMajorMinor(group); err != nil {
+			return nil, fmt.Errorf("the group %s is not valid: %s", group, err)
+		}
+	}
+
+	if err := SetupStorageDir(output.NewSilentPrint()); err != nil {
+		return nil, err
+	}
+
+	entries, err := ChannelMappingHistory()
+	if err != nil {
+		return nil, fmt.Errorf("get the channel mapping history failed: %s", err)
+	}
+
+	return entries, nil
+}
+
+// channelMappingHistoryDiffEntries returns entries by IDs, zero IDs select the latest entry and the one before it
+func channelMappingHistoryDiffEntries(entries []*ChannelMappingHistoryEntry, fromID, toID int) (*ChannelMappingHistoryEntry, *ChannelMappingHistoryEntry, error) {
+	toInd := len(entries) - 1
+	if toID != 0 {
+		toInd = channelMappingHistoryEntryIndex(entries, toID)
+		if toInd == -1 {
+			return nil, nil, fmt.Errorf("the channel mapping history entry #%d is not found", toID)
+		}
+	}
+
+	fromInd := toInd - 1
+	if fromID != 0 {
+		fromInd = channelMappingHistoryEntryIndex(entries, fromID)
+		if fromInd == -1 {
+			return nil, nil, fmt.Errorf("the channel mapping history entry #%d is not found", fromID)
+		}
+	}
+
+	if fromInd < 0 || toInd < 0 {
+		return nil, nil, fmt.Errorf("the channel mapping history has no changes yet")
+	}
+
+	return entries[fromInd], entries[toInd], nil
+}
+
+func channelMappingHistoryEntryIndex(entries []*ChannelMappingHistoryEntry, id int) int {
+	for ind, entry := range entries {
+		if entry.ID == id {
+			return ind
+		}
+	}
+
+	return -1
+}
+
+func formatSavedAt(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func versionOrDash(version string) string {
+	if version == "" {
+		return "-"
+	}
+
+	return version
+}
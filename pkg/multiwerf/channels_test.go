@@ -0,0 +1,65 @@
+package multiwerf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseChannelMapping(t *testing.T, data string) *ChannelMappingBase {
+	channelMapping := &ChannelMappingBase{}
+	assert.NoError(t, json.Unmarshal([]byte(data), channelMapping))
+
+	return channelMapping
+}
+
+func Test_channelMatrix(t *testing.T) {
+	local := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.10", "channels": [{"name": "stable", "version": "v1.10.0"}]},
+		{"group": "1.2", "channels": [{"name": "stable", "version": "v1.2.1"}, {"name": "alpha", "version": "v1.2.3"}, {"name": "ea", "version": "v1.2.2"}]}
+	]}`)
+	remote := parseChannelMapping(t, `{"multiwerf": [
+		{"group": "1.2", "channels": [{"name": "alpha", "version": "v1.2.4"}, {"name": "ea", "version": "v1.2.2"}, {"name": "rock-solid", "version": "v1.2.0"}]}
+	]}`)
+
+	assert.Equal(t, []*channelMatrixRow{
+		{Group: "1.2", Channel: "alpha", LocalVersion: "v1.2.3", RemoteVersion: "v1.2.4", Diff: ChangedChannelDiff},
+		{Group: "1.2", Channel: "ea", LocalVersion: "v1.2.2", RemoteVersion: "v1.2.2"},
+		{Group: "1.2", Channel: "stable", LocalVersion: "v1.2.1", Diff: RemovedChannelDiff},
+		{Group: "1.2", Channel: "rock-solid", RemoteVersion: "v1.2.0", Diff: AddedChannelDiff},
+		{Group: "1.10", Channel: "stable", LocalVersion: "v1.10.0", Diff: RemovedChannelDiff},
+	}, channelMatrix(local, remote, ""))
+
+	assert.Equal(t, []*channelMatrixRow{
+		{Group: "1.10", Channel: "stable", LocalVersion: "v1.10.0"},
+	}, channelMatrix(local, nil, "1.10"))
+}
+
+func Test_printChannelMatrix(t *testing.T) {
+	rows := []*channelMatrixRow{
+		{Group: "1.2", Channel: "alpha", LocalVersion: "v1.2.3", RemoteVersion: "v1.2.4", Diff: ChangedChannelDiff},
+		{Group: "1.2", Channel: "ea", LocalVersion: "v1.2.2", RemoteVersion: "v1.2.2"},
+		{Group: "1.2", Channel: "rock-solid", RemoteVersion: "v1.2.0", Diff: AddedChannelDiff},
+		{Group: "1.10", Channel: "stable", LocalVersion: "v1.10.0", Diff: RemovedChannelDiff},
+	}
+	installedVersions := map[string]bool{"v1.2.3": true}
+
+	var buf bytes.Buffer
+	assert.NoError(t, printChannelMatrix(&buf, rows, false, installedVersions))
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, []string{"GROUP", "ALPHA", "EA", "STABLE", "ROCK-SOLID"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"1.2", "v1.2.3", "*", "v1.2.2", "-", "-"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"1.10", "-", "-", "v1.10.0", "-"}, strings.Fields(lines[2]))
+
+	buf.Reset()
+	assert.NoError(t, printChannelMatrix(&buf, rows, true, installedVersions))
+
+	lines = strings.Split(buf.String(), "\n")
+	assert.Equal(t, []string{"GROUP", "ALPHA", "EA", "STABLE", "ROCK-SOLID"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"1.2", "v1.2.3", "*", "->", "v1.2.4", "v1.2.2", "-", "-", "->", "v1.2.0"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"1.10", "-", "-", "v1.10.0", "->", "-", "-"}, strings.Fields(lines[2]))
+}
@@ -0,0 +1,113 @@
+package multiwerf
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/http"
+)
+
+const progressBarWidth = 30
+
+// A delay between progress messages of a file
+var progressReportInterval = time.Second
+
+// downloadOptions returns download options from the --download-* flags.
+// The progress of downloading is sent as ActionMessages if messages is not nil.
+func downloadOptions(messages chan ActionMessage) http.DownloadOptions {
+	options := http.DownloadOptions{
+		ConnectTimeout: app.DownloadConnectTimeout,
+		ReadTimeout:    app.DownloadReadTimeout,
+		Retries:        app.DownloadRetries,
+	}
+
+	if messages != nil {
+		options.OnProgress = newProgressReporter(messages).report
+	}
+
+	return options
+}
+
+// progressReporter throttles progress events and sends them as messages with a textual progress bar.
+// Files that are downloaded faster than progressReportInterval are not reported.
+type progressReporter struct {
+	messages chan ActionMessage
+
+	mux   sync.Mutex
+	files map[string]*fileProgress
+}
+
+type fileProgress struct {
+	lastReportAt time.Time
+	reported     bool
+	finished     bool
+}
+
+func newProgressReporter(messages chan ActionMessage) *progressReporter {
+	return &progressReporter{
+		messages: messages,
+		files:    map[string]*fileProgress{},
+	}
+}
+
+func (r *progressReporter) report(event http.ProgressEvent) {
+	if !r.shouldReport(event) {
+		return
+	}
+
+	r.messages <- ActionMessage{msg: formatProgress(event)}
+}
+
+func (r *progressReporter) shouldReport(event http.ProgressEvent) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	progress, ok := r.files[event.Name]
+	if !ok {
+		progress = &fileProgress{lastReportAt: time.Now()}
+		r.files[event.Name] = progress
+	}
+
+	finished := event.Total >= 0 && event.Written >= event.Total
+	if finished {
+		if progress.finished || !progress.reported {
+			return false
+		}
+
+		progress.finished = true
+		return true
+	}
+
+	if time.Since(progress.lastReportAt) < progressReportInterval {
+		return false
+	}
+
+	progress.lastReportAt = time.Now()
+	progress.reported = true
+
+	return true
+}
+
+// formatProgress returns "name [=====>    ]  50% 1.0/2.0 MiB" or "name 1.0 MiB" if the size is unknown
+func formatProgress(event http.ProgressEvent) string {
+	if event.Total <= 0 {
+		return fmt.Sprintf("%s %s", event.Name, formatMiB(event.Written))
+	}
+
+	percent := event.Written * 100 / event.Total
+	filled := int(event.Written * progressBarWidth / event.Total)
+
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	return fmt.Sprintf("%s [%s] %3d%% %.1f/%s", event.Name, bar, percent, float64(event.Written)/(1024*1024), formatMiB(event.Total))
+}
+
+func formatMiB(bytes int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1024*1024))
+}
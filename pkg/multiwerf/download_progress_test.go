@@ -0,0 +1,44 @@
+package multiwerf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/http"
+)
+
+func Test_formatProgress(t *testing.T) {
+	assert.Equal(t,
+		"werf [===============>              ]  50% 1.0/2.0 MiB",
+		formatProgress(http.ProgressEvent{Name: "werf", Written: 1024 * 1024, Total: 2 * 1024 * 1024}),
+	)
+	assert.Equal(t,
+		"werf [==============================] 100% 2.0/2.0 MiB",
+		formatProgress(http.ProgressEvent{Name: "werf", Written: 2 * 1024 * 1024, Total: 2 * 1024 * 1024}),
+	)
+	assert.Equal(t,
+		"werf 1.5 MiB",
+		formatProgress(http.ProgressEvent{Name: "werf", Written: 1536 * 1024, Total: -1}),
+	)
+}
+
+func Test_progressReporter(t *testing.T) {
+	messages := make(chan ActionMessage, 10)
+	reporter := newProgressReporter(messages)
+
+	// the file downloaded faster than the report interval is not reported
+	reporter.report(http.ProgressEvent{Name: "SHA256SUMS", Written: 0, Total: 10})
+	reporter.report(http.ProgressEvent{Name: "SHA256SUMS", Written: 10, Total: 10})
+	assert.Len(t, messages, 0)
+
+	defer func(interval time.Duration) { progressReportInterval = interval }(progressReportInterval)
+	progressReportInterval = 0
+
+	reporter.report(http.ProgressEvent{Name: "werf", Written: 0, Total: 10})
+	reporter.report(http.ProgressEvent{Name: "werf", Written: 5, Total: 10})
+	reporter.report(http.ProgressEvent{Name: "werf", Written: 10, Total: 10})
+	reporter.report(http.ProgressEvent{Name: "werf", Written: 10, Total: 10})
+	assert.Len(t, messages, 3)
+}
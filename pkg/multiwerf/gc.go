@@ -3,18 +3,62 @@ package multiwerf
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/werf/lockgate"
 
+	"github.com/werf/multiwerf/pkg/app"
 	"github.com/werf/multiwerf/pkg/locker"
-
 	"github.com/werf/multiwerf/pkg/output"
+	"github.com/werf/multiwerf/pkg/util"
 )
 
 const GCLockName = "gc"
 
-func gc(printer output.Printer) error {
+// gcPinnedVersionMaxAge is the time the version selected by the exact version or the semver constraint is kept for,
+// so that pins that are not used anymore do not keep versions forever
+const gcPinnedVersionMaxAge = 30 * 24 * time.Hour
+
+// GCOptions configures retention policies that keep local versions missing in the current and the previous channel mappings
+type GCOptions struct {
+	// DryRun prints versions that would be removed without removing them
+	DryRun bool
+	// KeepPerGroup is the number of the highest local versions kept in every MAJOR.MINOR group
+	KeepPerGroup int
+	// KeepUsedWithin keeps versions used within the duration, the usage is recorded in the usage index
+	KeepUsedWithin time.Duration
+	// Keep is the list of exact versions and semver constraints of versions to keep
+	Keep []string
+	// MaxStorage is the disk budget in bytes for local versions, the least recently used versions are evicted until it is met.
+	// Versions from the keep list are never evicted.
+	MaxStorage int64
+	// TmpMaxAge is the age of interrupted downloads, temporary files and old multiwerf binaries to remove.
+	// DefaultGCTmpMaxAge is used if it is not set, the same for other ages.
+	TmpMaxAge time.Duration
+	// ScriptsMaxAge is the age of scripts generated by multiwerf use --as-file to remove
+	ScriptsMaxAge time.Duration
+	// LogsMaxAge is the age of logs to remove, logs larger than 10 MiB are removed regardless of the age
+	LogsMaxAge time.Duration
+}
+
+// gcVersion is the local version with its size and the last usage time
+type gcVersion struct {
+	Version    string
+	Size       int64
+	LastUsedAt time.Time
+	// PinnedAt is the last time the version has been selected by the exact version or the semver constraint
+	PinnedAt time.Time
+	// KeepReason is set if the version is kept by a retention policy
+	KeepReason string
+	// Evicted is set if the version is removed to fit the disk budget
+	Evicted bool
+}
+
+func gc(printer output.Printer, options GCOptions) error {
 	messages := make(chan ActionMessage, 0)
 	go func() {
 		isAcquired, lockHandle, err := locker.Locker.Acquire(GCLockName, lockgate.AcquireOptions{NonBlocking: true})
@@ -49,6 +93,7 @@ func gc(printer output.Printer) error {
 					continue
 				default:
 					messages <- ActionMessage{err: err}
+					return
 				}
 			}
 
@@ -85,6 +130,7 @@ func gc(printer output.Printer) error {
 		localVersions, err := localVersions()
 		if err != nil {
 			messages <- ActionMessage{err: err}
+			return
 		}
 
 		sort.Strings(localVersions)
@@ -95,19 +141,114 @@ func gc(printer output.Printer) error {
 			msgType: OkMsgType,
 		}
 
-		var versionsToRemove []string
-	localVersionsLoop:
-		for _, localVersion := range localVersions {
-			for _, version := range actualVersions {
-				if version == localVersion {
-					continue localVersionsLoop
+		usage, err := readUsageIndex()
+		if err != nil {
+			messages <- ActionMessage{
+				msg:     fmt.Sprintf("GC: Usage index ignored: %s", err),
+				msgType: WarnMsgType,
+				stage:   "gc",
+			}
+
+			usage = newUsageIndex()
+		}
+
+		var candidates []*gcVersion
+		for _, version := range localVersions {
+			candidate, err := newGCVersion(version, usage.Versions[version])
+			if err != nil {
+				messages <- ActionMessage{err: err}
+				return
+			}
+
+			candidate.PinnedAt = usage.Pinned[version]
+			candidates = append(candidates, candidate)
+		}
+
+		versionsToRemove, err := gcVersionsToRemove(candidates, actualVersions, options, time.Now())
+		if err != nil {
+			messages <- ActionMessage{err: err}
+			return
+		}
+
+		for _, candidate := range candidates {
+			if candidate.KeepReason != "" {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Keeping version %s: %s", candidate.Version, candidate.KeepReason),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+			}
+		}
+
+		var freed int64
+		var removedVersions []string
+		for _, candidate := range versionsToRemove {
+			var lruNote string
+			if candidate.Evicted {
+				lruNote = fmt.Sprintf(" (least recently used at %s)", candidate.LastUsedAt.Local().Format("2006-01-02 15:04:05"))
+			}
+
+			if options.DryRun {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Would remove version %v (%s)%s", candidate.Version, formatMiB(candidate.Size), lruNote),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+
+				freed += candidate.Size
+
+				continue
+			}
+
+			inUseReason, err := withVersionNotInUse(candidate.Version, func() error {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Removing version %v ...%s", candidate.Version, lruNote),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+
+				return os.RemoveAll(localVersionDirPath(candidate.Version))
+			})
+			if err != nil {
+				messages <- ActionMessage{err: err}
+				return
+			} else if inUseReason != "" {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Skipped version %v: %s", candidate.Version, inUseReason),
+					msgType: WarnMsgType,
+					stage:   "gc",
 				}
+
+				continue
 			}
 
-			versionsToRemove = append(versionsToRemove, localVersion)
+			freed += candidate.Size
+			removedVersions = append(removedVersions, candidate.Version)
+		}
+
+		// locks of removed versions are collected as well
+		lockedVersions := removedVersions
+		if options.DryRun {
+			lockedVersions = nil
+			for _, candidate := range versionsToRemove {
+				lockedVersions = append(lockedVersions, candidate.Version)
+			}
 		}
 
-		if len(versionsToRemove) == 0 {
+		fileCategories, err := gcFileCategories(options, lockedVersions, time.Now())
+		if err != nil {
+			messages <- ActionMessage{err: err}
+			return
+		}
+
+		hasFilesToRemove := false
+		for _, category := range fileCategories {
+			if len(category.Files) != 0 {
+				hasFilesToRemove = true
+			}
+		}
+
+		if len(versionsToRemove) == 0 && !hasFilesToRemove {
 			messages <- ActionMessage{
 				stage:   "gc",
 				msg:     "GC: Nothing to clean",
@@ -115,15 +256,91 @@ func gc(printer output.Printer) error {
 			}
 		}
 
-		for _, version := range versionsToRemove {
-			messages <- ActionMessage{
-				msg:     fmt.Sprintf("GC: Removing version %v ...", version),
-				msgType: OkMsgType,
-				stage:   "gc",
+		if len(removedVersions) != 0 {
+			if err := forgetVersionsUsage(removedVersions); err != nil {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Unable to update usage index: %s", err),
+					msgType: WarnMsgType,
+					stage:   "gc",
+				}
 			}
+		}
 
-			if err := os.RemoveAll(localVersionDirPath(version)); err != nil {
-				messages <- ActionMessage{err: err}
+		if options.MaxStorage > 0 {
+			var total int64
+			for _, candidate := range candidates {
+				total += candidate.Size
+			}
+
+			if total-freed > options.MaxStorage {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: Storage budget %s is not met: %s of versions are kept", formatMiB(options.MaxStorage), formatMiB(total-freed)),
+					msgType: WarnMsgType,
+					stage:   "gc",
+				}
+			}
+		}
+
+		for _, category := range fileCategories {
+			if len(category.Files) == 0 {
+				continue
+			}
+
+			var removed int
+			var categoryFreed int64
+			for _, file := range category.Files {
+				if options.DryRun {
+					removed++
+					categoryFreed += file.Size
+					continue
+				}
+
+				if ok, err := removeGCFile(file); err != nil {
+					messages <- ActionMessage{
+						msg:     fmt.Sprintf("GC: %s: %s", category.Name, err),
+						msgType: WarnMsgType,
+						stage:   "gc",
+					}
+				} else if ok {
+					removed++
+					categoryFreed += file.Size
+				}
+			}
+
+			freed += categoryFreed
+
+			if options.DryRun {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: %s: %d would be removed (%s)", category.Name, removed, formatMiB(categoryFreed)),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+			} else {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: %s: %d of %d removed (%s)", category.Name, removed, len(category.Files), formatMiB(categoryFreed)),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+			}
+		}
+
+		if !options.DryRun {
+			removeEmptyScriptsDirs()
+		}
+
+		if len(versionsToRemove) != 0 || hasFilesToRemove {
+			if options.DryRun {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: %s would be freed", formatMiB(freed)),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
+			} else {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("GC: %s freed", formatMiB(freed)),
+					msgType: OkMsgType,
+					stage:   "gc",
+				}
 			}
 		}
 
@@ -132,3 +349,235 @@ func gc(printer output.Printer) error {
 
 	return PrintActionMessages(messages, printer)
 }
+
+func validateGCOptions(options GCOptions) error {
+	if _, err := newVersionMatchers(options.Keep); err != nil {
+		return fmt.Errorf("bad --keep value: %s", err)
+	}
+
+	return nil
+}
+
+// gcRetentionArgs returns flags of retention policies to pass them to multiwerf update
+func gcRetentionArgs(options GCOptions) []string {
+	var args []string
+	if options.KeepPerGroup > 0 {
+		args = append(args, fmt.Sprintf("--keep-per-group=%d", options.KeepPerGroup))
+	}
+
+	if options.KeepUsedWithin > 0 {
+		args = append(args, fmt.Sprintf("--keep-used-within-days=%d", int(options.KeepUsedWithin/(24*time.Hour))))
+	}
+
+	for _, value := range options.Keep {
+		args = append(args, fmt.Sprintf("--keep=%s", value))
+	}
+
+	if options.MaxStorage > 0 {
+		args = append(args, fmt.Sprintf("--max-storage=%dB", options.MaxStorage))
+	}
+
+	return args
+}
+
+// newGCVersion returns the version with the last usage time from the usage index.
+// The version without the usage record, e.g. installed before usage tracking, is considered used when it was downloaded or accessed.
+// File times are not used for recorded versions because the hash verification on update reads the binary and changes its access time.
+func newGCVersion(version string, usedAt time.Time) (*gcVersion, error) {
+	dirPath := localVersionDirPath(version)
+	programPath := filepath.Join(dirPath, ReleaseFiles(app.AppPackageName, version, app.OsArch)["program"])
+
+	size, modTime, err := versionDirStat(dirPath, filepath.Base(programPath))
+	if err != nil {
+		return nil, err
+	}
+
+	if !usedAt.IsZero() {
+		return &gcVersion{Version: version, Size: size, LastUsedAt: usedAt}, nil
+	}
+
+	lastUsedAt := modTime
+
+	// the access time of the dir is not used because it is updated by reading the dir, e.g. by GC itself
+	if info, err := os.Stat(programPath); err == nil {
+		if accessTime := util.AccessTime(info); accessTime.After(lastUsedAt) {
+			lastUsedAt = accessTime
+		}
+	}
+
+	return &gcVersion{Version: version, Size: size, LastUsedAt: lastUsedAt}, nil
+}
+
+// gcVersionsToRemove returns local versions missing in actualVersions that are not kept by retention policies
+// and the least recently used versions that do not fit options.MaxStorage.
+// KeepReason is set for versions kept by retention policies.
+func gcVersionsToRemove(localVersions []*gcVersion, actualVersions []string, options GCOptions, now time.Time) ([]*gcVersion, error) {
+	// actual and pinned versions are never evicted
+	isActual := map[string]bool{}
+	notEvictable := map[string]bool{}
+	for _, version := range actualVersions {
+		isActual[version] = true
+		notEvictable[version] = true
+	}
+
+	for _, v := range localVersions {
+		if isPinnedVersion(v, now) {
+			notEvictable[v.Version] = true
+		}
+	}
+
+	keepMatchers, err := newVersionMatchers(options.Keep)
+	if err != nil {
+		return nil, err
+	}
+
+	highestInGroup := map[string]bool{}
+	if options.KeepPerGroup > 0 {
+		var versions []string
+		for _, v := range localVersions {
+			versions = append(versions, v.Version)
+		}
+
+		for _, groupVersions := range groupVersions(versions) {
+			sortVersions(groupVersions)
+			if len(groupVersions) > options.KeepPerGroup {
+				groupVersions = groupVersions[len(groupVersions)-options.KeepPerGroup:]
+			}
+
+			for _, version := range groupVersions {
+				highestInGroup[version] = true
+			}
+		}
+	}
+
+	var result []*gcVersion
+	for _, v := range localVersions {
+		if isActual[v.Version] {
+			continue
+		}
+
+		switch {
+		case matchVersion(keepMatchers, v.Version):
+			v.KeepReason = "in the keep list"
+		case isPinnedVersion(v, now):
+			v.KeepReason = fmt.Sprintf("pinned by the exact version or the constraint at %s", v.PinnedAt.Local().Format("2006-01-02 15:04:05"))
+		case highestInGroup[v.Version]:
+			v.KeepReason = fmt.Sprintf("one of %d highest versions of the group", options.KeepPerGroup)
+		case options.KeepUsedWithin > 0 && now.Sub(v.LastUsedAt) < options.KeepUsedWithin:
+			v.KeepReason = fmt.Sprintf("used at %s", v.LastUsedAt.Local().Format("2006-01-02 15:04:05"))
+		default:
+			result = append(result, v)
+		}
+	}
+
+	if options.MaxStorage > 0 {
+		result = append(result, lruVersionsToEvict(localVersions, result, notEvictable, keepMatchers, options.MaxStorage)...)
+	}
+
+	return result, nil
+}
+
+// lruVersionsToEvict returns the least recently used versions from the remaining ones that should be removed to fit maxStorage.
+// Versions from notEvictable and the keep list are never evicted.
+func lruVersionsToEvict(localVersions, versionsToRemove []*gcVersion, notEvictable map[string]bool, keepMatchers []versionMatcher, maxStorage int64) []*gcVersion {
+	isRemoved := map[*gcVersion]bool{}
+	for _, v := range versionsToRemove {
+		isRemoved[v] = true
+	}
+
+	var total int64
+	var evictable []*gcVersion
+	for _, v := range localVersions {
+		if isRemoved[v] {
+			continue
+		}
+
+		total += v.Size
+
+		if !notEvictable[v.Version] && !matchVersion(keepMatchers, v.Version) {
+			evictable = append(evictable, v)
+		}
+	}
+
+	sort.SliceStable(evictable, func(i, j int) bool {
+		if evictable[i].LastUsedAt.Equal(evictable[j].LastUsedAt) {
+			return evictable[i].Version < evictable[j].Version
+		}
+
+		return evictable[i].LastUsedAt.Before(evictable[j].LastUsedAt)
+	})
+
+	var result []*gcVersion
+	for _, v := range evictable {
+		if total <= maxStorage {
+			break
+		}
+
+		v.KeepReason = ""
+		v.Evicted = true
+		total -= v.Size
+		result = append(result, v)
+	}
+
+	return result
+}
+
+func isPinnedVersion(v *gcVersion, now time.Time) bool {
+	return !v.PinnedAt.IsZero() && now.Sub(v.PinnedAt) < gcPinnedVersionMaxAge
+}
+
+// groupVersions returns versions by MAJOR.MINOR groups, versions that cannot be parsed are skipped
+func groupVersions(versions []string) map[string][]string {
+	result := map[string][]string{}
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+
+		group := fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+		result[group] = append(result[group], version)
+	}
+
+	return result
+}
+
+// versionMatcher matches the exact version or the semver constraint
+type versionMatcher func(version string) bool
+
+func newVersionMatchers(versionsOrConstraints []string) ([]versionMatcher, error) {
+	var matchers []versionMatcher
+	for _, value := range versionsOrConstraints {
+		value := strings.TrimSpace(value)
+
+		switch {
+		case IsExactVersion(value):
+			exactVersion := normalizeExactVersion(value)
+			matchers = append(matchers, func(version string) bool { return version == exactVersion })
+		case IsVersionConstraint(value):
+			constraint, err := newSemverConstraint(value)
+			if err != nil {
+				return nil, err
+			}
+
+			matchers = append(matchers, func(version string) bool {
+				v, err := semver.NewVersion(version)
+				return err == nil && constraint.Check(v)
+			})
+		default:
+			return nil, fmt.Errorf("%q should be the exact version in form vMAJOR.MINOR.PATCH or the semver constraint", value)
+		}
+	}
+
+	return matchers, nil
+}
+
+func matchVersion(matchers []versionMatcher, version string) bool {
+	for _, match := range matchers {
+		if match(version) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,210 @@
+package multiwerf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+const (
+	DefaultGCTmpMaxAge     = 24 * time.Hour
+	DefaultGCScriptsMaxAge = 30 * 24 * time.Hour
+	DefaultGCLogsMaxAge    = 30 * 24 * time.Hour
+
+	// gcMaxLogSize is the size of the log that is removed regardless of its age
+	gcMaxLogSize = 10 * 1024 * 1024
+)
+
+// gcFile is the file or the directory that is not a version and can be removed by GC
+type gcFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+	// lockName is acquired exclusively to remove the file, the file is skipped if the lock is held
+	lockName string
+	// lockFile is the lock file that is locked with flock to remove it
+	lockFile bool
+}
+
+// gcFileCategory groups files in the GC report
+type gcFileCategory struct {
+	Name  string
+	Files []*gcFile
+}
+
+func (c *gcFileCategory) add(path string, info os.FileInfo) *gcFile {
+	file := &gcFile{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+	if info.IsDir() {
+		file.Size, _, _ = versionDirStat(path, "")
+	}
+
+	c.Files = append(c.Files, file)
+
+	return file
+}
+
+// gcFileCategories returns files that are not versions and should be removed by categories,
+// removedVersions are versions removed by GC, their lock files are removed as well.
+// Every category is returned even if there is nothing to remove.
+func gcFileCategories(options GCOptions, removedVersions []string, now time.Time) ([]*gcFileCategory, error) {
+	tmpMaxAge := durationOrDefault(options.TmpMaxAge, DefaultGCTmpMaxAge)
+	scriptsMaxAge := durationOrDefault(options.ScriptsMaxAge, DefaultGCScriptsMaxAge)
+	logsMaxAge := durationOrDefault(options.LogsMaxAge, DefaultGCLogsMaxAge)
+
+	tmp := &gcFileCategory{Name: "Temporary files"}
+	oldBinaries := &gcFileCategory{Name: "Old multiwerf binaries"}
+	scripts := &gcFileCategory{Name: "Use scripts"}
+	logs := &gcFileCategory{Name: "Logs"}
+	locks := &gcFileCategory{Name: "Locks"}
+
+	// interrupted downloads <version>-*, channel mapping temporary files and binaries replaced by self-update .<name>.old
+	if err := walkDirEntries(TmpDir, func(path string, info os.FileInfo) {
+		if now.Sub(info.ModTime()) < tmpMaxAge {
+			return
+		}
+
+		if strings.HasPrefix(info.Name(), ".") && strings.HasSuffix(info.Name(), ".old") {
+			oldBinaries.add(path, info)
+			return
+		}
+
+		file := tmp.add(path, info)
+		if info.IsDir() {
+			if ind := strings.LastIndex(info.Name(), "-"); ind > 0 && IsExactVersion(info.Name()[:ind]) {
+				// the version is being downloaded
+				file.lockName = info.Name()[:ind]
+			}
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	// scripts are regenerated by multiwerf use --as-file
+	scriptsDir := filepath.Join(StorageDir, "scripts")
+	if err := walkDirEntries(scriptsDir, func(dirPath string, dirInfo os.FileInfo) {
+		if !dirInfo.IsDir() {
+			return
+		}
+
+		_ = walkDirEntries(dirPath, func(path string, info os.FileInfo) {
+			if strings.HasPrefix(info.Name(), "werf_source") && now.Sub(info.ModTime()) >= scriptsMaxAge {
+				scripts.add(path, info)
+			}
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, path := range []string{
+		filepath.Join(StorageDir, "multiwerf_use_background_update.log"),
+		filepath.Join(StorageDir, "multiwerf_use_first_werf_path.log"),
+		trdlLogPath(),
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			if isNotExistError(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if now.Sub(info.ModTime()) >= logsMaxAge || info.Size() > gcMaxLogSize {
+			logs.add(path, info)
+		}
+	}
+
+	// lock files are removed only with their versions, other locks can be held at any time
+	// and the removed lock file would let another process lock the new file with the same path
+	for _, version := range removedVersions {
+		for _, lockName := range []string{version, versionInUseLockName(version)} {
+			path := locker.LockFilePath(lockName)
+
+			info, err := os.Stat(path)
+			if err != nil {
+				if isNotExistError(err) {
+					continue
+				}
+
+				return nil, err
+			}
+
+			locks.add(path, info).lockFile = true
+		}
+	}
+
+	return []*gcFileCategory{tmp, oldBinaries, scripts, logs, locks}, nil
+}
+
+// removeGCFile removes the file if it is not used, false is returned if the file is skipped
+func removeGCFile(file *gcFile) (bool, error) {
+	switch {
+	case file.lockName != "":
+		isAcquired, lockHandle, err := locker.Locker.Acquire(file.lockName, lockgate.AcquireOptions{NonBlocking: true})
+		if err != nil {
+			return false, fmt.Errorf("acquire lock %s failed: %s", file.lockName, err)
+		} else if !isAcquired {
+			return false, nil
+		}
+		defer func() { _ = locker.Locker.Release(lockHandle) }()
+	case file.lockFile:
+		lock := flock.New(file.Path)
+		isLocked, err := lock.TryLock()
+		if err != nil {
+			return false, fmt.Errorf("lock file %s failed: %s", file.Path, err)
+		} else if !isLocked {
+			return false, nil
+		}
+		defer func() { _ = lock.Unlock() }()
+	}
+
+	if err := os.RemoveAll(file.Path); err != nil {
+		return false, fmt.Errorf("remove %s failed: %s", file.Path, err)
+	}
+
+	return true, nil
+}
+
+// walkDirEntries calls f for every entry of the dir, nothing is done if the dir does not exist
+func walkDirEntries(dir string, f func(path string, info os.FileInfo)) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if isNotExistError(err) {
+			return nil
+		}
+
+		return fmt.Errorf("read dir failed %s: %s", dir, err)
+	}
+
+	for _, entry := range entries {
+		f(filepath.Join(dir, entry.Name()), entry)
+	}
+
+	return nil
+}
+
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration == 0 {
+		return defaultDuration
+	}
+
+	return duration
+}
+
+// removeEmptyScriptsDirs removes group/channel dirs left without scripts
+func removeEmptyScriptsDirs() {
+	_ = walkDirEntries(filepath.Join(StorageDir, "scripts"), func(path string, info os.FileInfo) {
+		if info.IsDir() {
+			// fails if the dir is not empty
+			_ = os.Remove(path)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+func Test_gcFileCategories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-gc-files-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir, tmpDir string) { StorageDir, TmpDir = storageDir, tmpDir }(StorageDir, TmpDir)
+	StorageDir = filepath.Join(dir, "storage")
+	TmpDir = filepath.Join(StorageDir, "tmp")
+	assert.NoError(t, locker.Init(filepath.Join(StorageDir, "locks")))
+
+	defer func(home string) { _ = os.Setenv("HOME", home) }(os.Getenv("HOME"))
+	assert.NoError(t, os.Setenv("HOME", filepath.Join(dir, "home")))
+
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+	writeFile := func(path string, size int, modTime time.Time) string {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+		return path
+	}
+
+	writeFile(filepath.Join(StorageDir, "v1.1.0", "werf"), 10, old)
+	interruptedDownload := writeFile(filepath.Join(TmpDir, "v1.1.1-123", "werf"), 10, old)
+	assert.NoError(t, os.Chtimes(filepath.Dir(interruptedDownload), old, old))
+	writeFile(filepath.Join(TmpDir, "v1.1.2-456", "werf"), 10, now)
+	oldBinary := writeFile(filepath.Join(TmpDir, ".multiwerf.old"), 10, old)
+	oldScript := writeFile(filepath.Join(StorageDir, "scripts", "1.1-stable", "werf_source_with_force_remote_check"), 10, old)
+	writeFile(filepath.Join(StorageDir, "scripts", "1.1-stable", "werf_source"), 10, now)
+	oldLog := writeFile(filepath.Join(StorageDir, "multiwerf_use_background_update.log"), 10, old)
+	largeLog := writeFile(trdlLogPath(), gcMaxLogSize+1, now)
+	writeFile(filepath.Join(StorageDir, "multiwerf_use_first_werf_path.log"), 10, now)
+	removedVersionLock := writeFile(locker.LockFilePath("v1.0.0"), 0, now)
+	removedVersionInUseLock := writeFile(locker.LockFilePath(versionInUseLockName("v1.0.0")), 0, now)
+	writeFile(locker.LockFilePath("v1.1.0"), 0, old)
+	writeFile(locker.LockFilePath(GCLockName), 0, old)
+	writeFile(locker.LockFilePath(SystemStoreIndexLockName), 0, old)
+
+	categories, err := gcFileCategories(GCOptions{}, []string{"v1.0.0", "v0.9.0"}, now)
+	assert.NoError(t, err)
+
+	paths := map[string][]string{}
+	for _, category := range categories {
+		for _, file := range category.Files {
+			paths[category.Name] = append(paths[category.Name], file.Path)
+		}
+	}
+
+	assert.Equal(t, map[string][]string{
+		"Temporary files":        {filepath.Dir(interruptedDownload)},
+		"Old multiwerf binaries": {oldBinary},
+		"Use scripts":            {oldScript},
+		"Logs":                   {oldLog, largeLog},
+		"Locks":                  {removedVersionLock, removedVersionInUseLock},
+	}, paths)
+
+	assert.Equal(t, "v1.1.1", categories[0].Files[0].lockName, "the download should be locked before removal")
+	assert.Equal(t, int64(10), categories[0].Files[0].Size)
+
+	categories, err = gcFileCategories(GCOptions{ScriptsMaxAge: 90 * 24 * time.Hour}, []string{"v1.0.0"}, now)
+	assert.NoError(t, err)
+	assert.Empty(t, categories[2].Files)
+
+	for _, category := range categories {
+		for _, file := range category.Files {
+			removed, err := removeGCFile(file)
+			assert.NoError(t, err)
+			assert.True(t, removed)
+
+			_, err = os.Stat(file.Path)
+			assert.True(t, os.IsNotExist(err))
+		}
+	}
+}
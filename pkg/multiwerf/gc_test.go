@@ -0,0 +1,178 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/app"
+)
+
+func Test_gcVersionsToRemove(t *testing.T) {
+	now := time.Now()
+	newLocalVersions := func() []*gcVersion {
+		return []*gcVersion{
+			{Version: "v1.1.0", LastUsedAt: now.Add(-30 * 24 * time.Hour)},
+			{Version: "v1.1.1", LastUsedAt: now.Add(-30 * 24 * time.Hour)},
+			{Version: "v1.1.2", LastUsedAt: now.Add(-30 * 24 * time.Hour)},
+			{Version: "v1.1.10", LastUsedAt: now.Add(-time.Hour)},
+			{Version: "v1.2.0", LastUsedAt: now.Add(-30 * 24 * time.Hour)},
+			{Version: "v1.2.1", LastUsedAt: now.Add(-30 * 24 * time.Hour)},
+		}
+	}
+	actualVersions := []string{"v1.2.1"}
+
+	versionsOf := func(versions []*gcVersion) []string {
+		var result []string
+		for _, v := range versions {
+			result = append(result, v.Version)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		options  GCOptions
+		expected []string
+	}{
+		{
+			name:     "without retention policies",
+			expected: []string{"v1.1.0", "v1.1.1", "v1.1.2", "v1.1.10", "v1.2.0"},
+		},
+		{
+			name:     "keep per group",
+			options:  GCOptions{KeepPerGroup: 2},
+			expected: []string{"v1.1.0", "v1.1.1"},
+		},
+		{
+			name:     "keep used within",
+			options:  GCOptions{KeepUsedWithin: 7 * 24 * time.Hour},
+			expected: []string{"v1.1.0", "v1.1.1", "v1.1.2", "v1.2.0"},
+		},
+		{
+			name:     "keep exact versions and constraints",
+			options:  GCOptions{Keep: []string{"1.1.0", "~1.2.0"}},
+			expected: []string{"v1.1.1", "v1.1.2", "v1.1.10"},
+		},
+		{
+			name:     "combined policies",
+			options:  GCOptions{KeepPerGroup: 1, KeepUsedWithin: 7 * 24 * time.Hour, Keep: []string{"v1.1.0"}},
+			expected: []string{"v1.1.1", "v1.1.2", "v1.2.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localVersions := newLocalVersions()
+			versionsToRemove, err := gcVersionsToRemove(localVersions, actualVersions, tt.options, now)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, versionsOf(versionsToRemove))
+
+			for _, v := range versionsToRemove {
+				assert.Empty(t, v.KeepReason)
+			}
+		})
+	}
+
+	_, err := gcVersionsToRemove(newLocalVersions(), actualVersions, GCOptions{Keep: []string{"stable"}}, now)
+	assert.Error(t, err)
+}
+
+func Test_lruVersionsToEvict(t *testing.T) {
+	now := time.Now()
+	localVersions := []*gcVersion{
+		{Version: "v1.1.0", Size: 100, LastUsedAt: now.Add(-3 * time.Hour)},
+		{Version: "v1.1.1", Size: 100, LastUsedAt: now.Add(-6 * time.Hour)},
+		{Version: "v1.2.0", Size: 100, LastUsedAt: now.Add(-4 * time.Hour)},
+		{Version: "v1.2.1", Size: 100, LastUsedAt: now.Add(-2 * time.Hour)},
+		{Version: "v1.3.0", Size: 100, LastUsedAt: now.Add(-5 * time.Hour)},
+	}
+
+	versionsToRemove, err := gcVersionsToRemove(localVersions, []string{"v1.3.0"}, GCOptions{MaxStorage: 250, KeepUsedWithin: 24 * time.Hour, Keep: []string{"v1.1.1"}}, now)
+	assert.NoError(t, err)
+
+	var versions []string
+	for _, v := range versionsToRemove {
+		versions = append(versions, v.Version)
+		assert.True(t, v.Evicted)
+		assert.Empty(t, v.KeepReason)
+	}
+
+	// v1.3.0 is actual and v1.1.1 is in the keep list, the rest are evicted in the least recently used order
+	assert.Equal(t, []string{"v1.2.0", "v1.1.0", "v1.2.1"}, versions)
+
+	localVersions = []*gcVersion{
+		{Version: "v1.1.0", Size: 100, LastUsedAt: now.Add(-3 * time.Hour)},
+		{Version: "v1.1.1", Size: 100, LastUsedAt: now.Add(-time.Hour)},
+		{Version: "v1.2.0", Size: 100, LastUsedAt: now.Add(-4 * time.Hour)},
+	}
+
+	evicted := lruVersionsToEvict(localVersions, nil, nil, nil, 100)
+	assert.Len(t, evicted, 2)
+	assert.Equal(t, "v1.2.0", evicted[0].Version)
+	assert.Equal(t, "v1.1.0", evicted[1].Version)
+}
+
+func Test_gcVersionsToRemove_pinned(t *testing.T) {
+	now := time.Now()
+	localVersions := []*gcVersion{
+		{Version: "v1.1.0", Size: 100, LastUsedAt: now.Add(-2 * time.Hour), PinnedAt: now.Add(-time.Hour)},
+		{Version: "v1.1.1", Size: 100, LastUsedAt: now.Add(-time.Hour), PinnedAt: now.Add(-60 * 24 * time.Hour)},
+		{Version: "v1.2.0", Size: 100, LastUsedAt: now.Add(-time.Hour)},
+	}
+
+	versionsToRemove, err := gcVersionsToRemove(localVersions, []string{"v1.2.0"}, GCOptions{MaxStorage: 100}, now)
+	assert.NoError(t, err)
+
+	// v1.1.1 has not been pinned for too long, the pinned v1.1.0 is not evicted even though the budget is not met
+	assert.Len(t, versionsToRemove, 1)
+	assert.Equal(t, "v1.1.1", versionsToRemove[0].Version)
+	assert.False(t, versionsToRemove[0].Evicted)
+	assert.Contains(t, localVersions[0].KeepReason, "pinned")
+}
+
+func Test_newGCVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-gc-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir string) { StorageDir = storageDir }(StorageDir)
+	StorageDir = dir
+
+	downloadedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	programPath := filepath.Join(localVersionDirPath("v1.1.0"), ReleaseFiles(app.AppPackageName, "v1.1.0", app.OsArch)["program"])
+	assert.NoError(t, os.MkdirAll(filepath.Dir(programPath), 0755))
+	assert.NoError(t, ioutil.WriteFile(programPath, make([]byte, 10), 0755))
+	assert.NoError(t, os.Chtimes(programPath, downloadedAt, downloadedAt))
+	assert.NoError(t, os.Chtimes(filepath.Dir(programPath), downloadedAt, downloadedAt))
+
+	// the version without the usage record
+	v, err := newGCVersion("v1.1.0", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), v.Size)
+	assert.True(t, v.LastUsedAt.Equal(downloadedAt), v.LastUsedAt)
+
+	usedAt := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	v, err = newGCVersion("v1.1.0", usedAt)
+	assert.NoError(t, err)
+	assert.True(t, v.LastUsedAt.Equal(usedAt), v.LastUsedAt)
+
+	// the binary is read by the hash verification on update
+	verifiedAt := time.Now().Truncate(time.Second)
+	assert.NoError(t, os.Chtimes(programPath, verifiedAt, downloadedAt))
+	v, err = newGCVersion("v1.1.0", usedAt)
+	assert.NoError(t, err)
+	assert.True(t, v.LastUsedAt.Equal(usedAt), "the access time should not override the usage record: %s", v.LastUsedAt)
+}
+
+func Test_gcRetentionArgs(t *testing.T) {
+	assert.Empty(t, gcRetentionArgs(GCOptions{}))
+	assert.Equal(t,
+		[]string{"--keep-per-group=2", "--keep-used-within-days=7", "--keep=v1.1.0", "--keep=~1.2.0", "--max-storage=1500B"},
+		gcRetentionArgs(GCOptions{KeepPerGroup: 2, KeepUsedWithin: 7 * 24 * time.Hour, Keep: []string{"v1.1.0", "~1.2.0"}, MaxStorage: 1500}),
+	)
+}
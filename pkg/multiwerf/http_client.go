@@ -0,0 +1,18 @@
+package multiwerf
+
+import (
+	netHttp "net/http"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/http"
+)
+
+// newHTTPClient returns the client for channel mappings and signatures configured with --http-*, --ca-bundle and --user-agent flags
+func newHTTPClient() (*netHttp.Client, error) {
+	return http.NewClient(http.ClientOptions{
+		ConnectTimeout: app.HTTPConnectTimeout,
+		Timeout:        app.HTTPTimeout,
+		CABundlePath:   app.CABundlePath,
+		UserAgent:      app.UserAgent,
+	})
+}
@@ -0,0 +1,249 @@
+package multiwerf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+	"github.com/werf/multiwerf/pkg/output"
+)
+
+const (
+	TableListFormat = "table"
+	JSONListFormat  = "json"
+)
+
+const (
+	VerifiedVersionStatus    = "verified"
+	NotVerifiedVersionStatus = "not verified"
+	LockedVersionStatus      = "locked"
+)
+
+type ListOptions struct {
+	Format string
+}
+
+// LocalVersion describes the version stored in StorageDir
+type LocalVersion struct {
+	Version     string    `json:"version"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	InstalledAt time.Time `json:"installed_at"`
+	// Status is verified, not verified (invalid or corrupted files) or locked (the version is being updated)
+	Status string `json:"status"`
+	// Channels are group/channel pairs of the current channel mapping that point to the version
+	Channels []string `json:"channels"`
+	// OldChannels are group/channel pairs of the previous channel mapping that point to the version
+	OldChannels []string `json:"old_channels"`
+}
+
+// List prints versions stored in StorageDir in table or json format
+func List(options ListOptions) error {
+	printer := output.NewSilentPrint()
+
+	if err := SetupStorageDir(printer); err != nil {
+		return err
+	}
+
+	var localVersions []*LocalVersion
+	messages := make(chan ActionMessage, 0)
+
+	go func() {
+		var err error
+		localVersions, err = listLocalVersions(messages)
+		if err != nil {
+			messages <- ActionMessage{err: err}
+		}
+
+		messages <- ActionMessage{action: "exit"}
+	}()
+
+	if err := PrintActionMessages(messages, printer); err != nil {
+		return err
+	}
+
+	switch options.Format {
+	case JSONListFormat:
+		return printLocalVersionsJSON(os.Stdout, localVersions)
+	default:
+		return printLocalVersionsTable(os.Stdout, localVersions)
+	}
+}
+
+func listLocalVersions(messages chan ActionMessage) ([]*LocalVersion, error) {
+	versions, err := localVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	sortVersions(versions)
+
+	currentChannels, err := localChannelMappingVersionChannels(localChannelMappingPath())
+	if err != nil {
+		return nil, err
+	}
+
+	oldChannels, err := localChannelMappingVersionChannels(localOldChannelMappingPath())
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*LocalVersion{}
+	for _, version := range versions {
+		localVersion, err := newLocalVersion(messages, version)
+		if err != nil {
+			return nil, err
+		}
+
+		// versions without channels keep empty lists, so they are printed as [] in json
+		if channels, ok := currentChannels[version]; ok {
+			localVersion.Channels = channels
+		}
+
+		if channels, ok := oldChannels[version]; ok {
+			localVersion.OldChannels = channels
+		}
+
+		result = append(result, localVersion)
+	}
+
+	return result, nil
+}
+
+func newLocalVersion(messages chan ActionMessage, version string) (*LocalVersion, error) {
+	dirPath := localVersionDirPath(version)
+	localVersion := &LocalVersion{
+		Version:     version,
+		Path:        dirPath,
+		Channels:    []string{},
+		OldChannels: []string{},
+	}
+
+	// the version is not verified while it is being downloaded or removed
+	isAcquired, lockHandle, err := locker.Locker.Acquire(version, lockgate.AcquireOptions{NonBlocking: true, Shared: true})
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock for version %s failed: %s", version, err)
+	} else if !isAcquired {
+		localVersion.Status = LockedVersionStatus
+		return localVersion, nil
+	}
+	defer func() { _ = locker.Locker.Release(lockHandle) }()
+
+	size, installedAt, err := versionDirStat(dirPath, ReleaseFiles(app.AppPackageName, version, app.OsArch)["program"])
+	if err != nil {
+		return nil, err
+	}
+
+	localVersion.Size = size
+	localVersion.InstalledAt = installedAt
+
+	binInfo, err := verifiedBinaryInfo(messages, dirPath, version)
+	if err != nil {
+		return nil, fmt.Errorf("the local version %s verification failed: %s", version, err)
+	}
+
+	if binInfo != nil && binInfo.HashVerified {
+		localVersion.Status = VerifiedVersionStatus
+	} else {
+		localVersion.Status = NotVerifiedVersionStatus
+	}
+
+	return localVersion, nil
+}
+
+// versionDirStat returns the total size of files in the version directory and
+// the modification time of the program file or the directory if the program file does not exist
+func versionDirStat(dirPath string, programFile string) (int64, time.Time, error) {
+	var size int64
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("walk dir failed %s: %s", dirPath, err)
+	}
+
+	info, err := os.Stat(filepath.Join(dirPath, programFile))
+	if err != nil && isNotExistError(err) {
+		info, err = os.Stat(dirPath)
+	}
+
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return size, info.ModTime(), nil
+}
+
+// localChannelMappingVersionChannels returns group/channel pairs by versions of the local channel mapping
+// or empty map if the channel mapping does not exist
+func localChannelMappingVersionChannels(channelMappingPath string) (map[string][]string, error) {
+	channelMapping, err := newLocalChannelMapping(channelMappingPath)
+	if err != nil {
+		switch err.(type) {
+		case LocalChannelMappingNotFoundError:
+			return map[string][]string{}, nil
+		default:
+			return nil, fmt.Errorf("get the local channel mapping %s failed: %s", channelMappingPath, err)
+		}
+	}
+
+	return channelMapping.VersionChannels(), nil
+}
+
+func printLocalVersionsJSON(w io.Writer, localVersions []*LocalVersion) error {
+	data, err := json.MarshalIndent(localVersions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func printLocalVersionsTable(w io.Writer, localVersions []*LocalVersion) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "VERSION\tSIZE\tINSTALLED\tSTATUS\tCHANNELS\tOLD CHANNELS")
+	for _, v := range localVersions {
+		installedAt := "-"
+		if !v.InstalledAt.IsZero() {
+			installedAt = v.InstalledAt.Local().Format("2006-01-02 15:04:05")
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Version,
+			formatMiB(v.Size),
+			installedAt,
+			v.Status,
+			joinOrDash(v.Channels),
+			joinOrDash(v.OldChannels),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+
+	return strings.Join(values, ",")
+}
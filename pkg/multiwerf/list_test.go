@@ -0,0 +1,40 @@
+package multiwerf
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ChannelMappingBase_VersionChannels(t *testing.T) {
+	var channelMapping ChannelMappingBase
+	err := json.Unmarshal([]byte(`{"multiwerf": [
+		{"group": "1.1", "channels": [{"name": "alpha", "version": "v1.1.2"}, {"name": "stable", "version": "v1.1.1"}]},
+		{"group": "1.2", "channels": [{"name": "alpha", "version": "v1.1.2"}]}
+	]}`), &channelMapping)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"v1.1.2": {"1.1/alpha", "1.2/alpha"},
+		"v1.1.1": {"1.1/stable"},
+	}, channelMapping.VersionChannels())
+}
+
+func Test_printLocalVersionsTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := printLocalVersionsTable(&buf, []*LocalVersion{
+		{Version: "v1.1.1", Size: 1024 * 1024, Status: VerifiedVersionStatus, Channels: []string{"1.1/stable", "1.1/ea"}},
+		{Version: "v1.1.2", Status: LockedVersionStatus},
+	})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, []string{"VERSION", "SIZE", "INSTALLED", "STATUS", "CHANNELS", "OLD", "CHANNELS"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"v1.1.1", "1.0", "MiB", "-", "verified", "1.1/stable,1.1/ea", "-"}, strings.Fields(lines[1]))
+		assert.Equal(t, []string{"v1.1.2", "0.0", "MiB", "-", "locked", "-", "-"}, strings.Fields(lines[2]))
+	}
+}
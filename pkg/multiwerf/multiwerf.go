@@ -9,8 +9,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/werf/multiwerf/pkg/trdlexec"
 
@@ -23,6 +25,8 @@ import (
 var (
 	StorageDir string
 	TmpDir     string
+	// SystemStoreDir is the read-only store of versions shared by all users, it is searched before StorageDir
+	SystemStoreDir string
 )
 
 type SelfUpdateOptions struct {
@@ -66,22 +70,25 @@ type UpdateOptions struct {
 	TryRemoteChannelMapping bool
 	WithCache               bool
 	WithGC                  bool
+	GCOptions               GCOptions
 	OutputFile              string
 	TryTrdl                 bool
 	AutoInstallTrdl         bool
 }
 
-// Update checks for the actual version for group/channel and downloads it to StorageDir if it does not already exist
+// Update performs self-update and downloads the selected version to StorageDir if it does not already exist
 //
 // Arguments:
 //
-// - group - a major.minor version to update
-// - channel - a string with channel name
-// - options.SkipSelfUpdate - a boolean to perform self-update
-// - options.WithCache - a boolean to try or not getting remote channel mapping
-// - options.WithGC - a boolean to run GC before update
+// - groupOrVersion - a major.minor group, an exact version or a semver constraint, the project config is used if it is empty
+// - channel - a string with channel name, it is used only with the group
+// - options.SkipSelfUpdate - a boolean to skip self-update
+// - options.TryRemoteChannelMapping - a boolean to get the remote channel mapping or use the local one
+// - options.WithCache - a boolean to skip the remote channel mapping check if it has been done recently
+// - options.WithGC - a boolean to run GC with options.GCOptions before update of the channel version
 // - options.OutputFile - a string to write update output to file
-func Update(group, channel string, options UpdateOptions) (err error) {
+// - options.TryTrdl, options.AutoInstallTrdl - booleans to delegate update of the channel version to trdl and install it if needed
+func Update(groupOrVersion, channel string, options UpdateOptions) (err error) {
 	var w io.Writer
 	if options.OutputFile != "" {
 		dirPath := filepath.Dir(options.OutputFile)
@@ -102,7 +109,12 @@ func Update(group, channel string, options UpdateOptions) (err error) {
 
 	printer := output.NewSimplePrint(w)
 
-	if err := ValidateGroup(group, printer); err != nil {
+	if err := validateGCOptions(options.GCOptions); err != nil {
+		return err
+	}
+
+	selector, err := resolveVersionSelector(groupOrVersion, channel, printer)
+	if err != nil {
 		return err
 	}
 
@@ -114,8 +126,9 @@ func Update(group, channel string, options UpdateOptions) (err error) {
 		return err
 	}
 
-	if options.TryTrdl {
-		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfUpdateCommand(group, channel, os.Stdout, os.Stdout), options.AutoInstallTrdl)
+	// trdl supports only channels
+	if options.TryTrdl && selector.IsChannel() {
+		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfUpdateCommand(selector.Group, selector.Channel, os.Stdout, os.Stdout), options.AutoInstallTrdl)
 		if err != nil {
 			os.RemoveAll(filepath.Join(StorageDir, "self-update.delay"))
 		}
@@ -124,28 +137,32 @@ func Update(group, channel string, options UpdateOptions) (err error) {
 		}
 	}
 
-	if options.WithGC {
-		if err := gc(printer); err != nil {
+	// GC removes versions that are not in the channel mapping including the selected one
+	if options.WithGC && selector.IsChannel() {
+		if err := gc(printer, options.GCOptions); err != nil {
 			return err
 		}
 	}
 
-	tryRemoteChannelMapping, err := processTryRemoteChannelMapping(printer, channel, options.WithCache, options.TryRemoteChannelMapping)
-	if err != nil {
-		return err
+	var tryRemoteChannelMapping bool
+	if selector.IsChannel() {
+		tryRemoteChannelMapping, err = processTryRemoteChannelMapping(printer, options.WithCache, options.TryRemoteChannelMapping)
+		if err != nil {
+			return err
+		}
 	}
 
 	messages := make(chan ActionMessage, 0)
 
 	go func() {
-		UpdateChannelVersionBinary(messages, group, channel, tryRemoteChannelMapping)
+		updateSelectedVersionBinary(messages, selector, tryRemoteChannelMapping)
 		messages <- ActionMessage{action: "exit"}
 	}()
 
 	return PrintActionMessages(messages, printer)
 }
 
-func processTryRemoteChannelMapping(printer output.Printer, channel string, withCache, tryRemoteChannelMapping bool) (bool, error) {
+func processTryRemoteChannelMapping(printer output.Printer, withCache, tryRemoteChannelMapping bool) (bool, error) {
 	isLocalChannelMappingFileExist, err := isLocalChannelMappingFileExist()
 	if err != nil {
 		return false, err
@@ -159,12 +176,7 @@ func processTryRemoteChannelMapping(printer output.Printer, channel string, with
 		tryRemoteChannelMappingDelay := DelayFile{
 			Filename: filepath.Join(StorageDir, "try-remote-channel-mapping.delay"),
 		}
-
-		if channel == "alpha" || channel == "beta" {
-			tryRemoteChannelMappingDelay.WithDelay(app.AlphaBetaUpdateDelay)
-		} else {
-			tryRemoteChannelMappingDelay.WithDelay(app.UpdateDelay)
-		}
+		tryRemoteChannelMappingDelay.WithDelay(app.ChannelMappingUpdateDelay)
 
 		remains := tryRemoteChannelMappingDelay.TimeRemains()
 		if remains != "" && isLocalChannelMappingFileExist {
@@ -193,12 +205,13 @@ type UseOptions struct {
 	SkipSelfUpdate          bool
 	TryRemoteChannelMapping bool
 	WithGC                  bool
+	GCOptions               GCOptions
 	TryTrdl                 bool
 	AutoInstallTrdl         bool
 }
 
 func tryTrdlUse(group, channel string, shell string, options UseOptions) (bool, error) {
-	logPath := filepath.Join(os.Getenv("HOME"), ".multiwerf", "trdl", "log")
+	logPath := trdlLogPath()
 	if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
 		return false, fmt.Errorf("unable to create dir %s: %s", filepath.Dir(logPath), err)
 	}
@@ -237,12 +250,13 @@ func tryTrdlUse(group, channel string, shell string, options UseOptions) (bool,
 // * prints a shell script or
 // * generates a shell script file and prints the path
 //
-// The script includes two parts for defined group/version based on local channel mapping:
+// The script includes two parts for defined group/channel based on local channel mapping or the exact version:
 // * multiwerf update procedure that will be performed on background or foreground and
 // * werf alias that uses path to the actual werf binary
-func Use(group, channel string, shell string, options UseOptions) (err error) {
+func Use(groupOrVersion, channel string, shell string, options UseOptions) (err error) {
 	printer := output.NewSilentPrint()
-	if err := ValidateGroup(group, printer); err != nil {
+	selector, err := resolveVersionSelector(groupOrVersion, channel, printer)
+	if err != nil {
 		return err
 	}
 
@@ -250,8 +264,8 @@ func Use(group, channel string, shell string, options UseOptions) (err error) {
 		return err
 	}
 
-	if options.TryTrdl {
-		done, err := tryTrdlUse(group, channel, shell, options)
+	if options.TryTrdl && selector.IsChannel() {
+		done, err := tryTrdlUse(selector.Group, selector.Channel, shell, options)
 		if err != nil {
 			os.RemoveAll(filepath.Join(StorageDir, "self-update.delay"))
 		}
@@ -263,7 +277,7 @@ func Use(group, channel string, shell string, options UseOptions) (err error) {
 	firstWerfPathLogPath := filepath.Join(StorageDir, "multiwerf_use_first_werf_path.log")
 	backgroundUpdateLogPath := filepath.Join(StorageDir, "multiwerf_use_background_update.log")
 
-	groupAndChannelArgs := []string{group, channel}
+	groupAndChannelArgs := selector.Args()
 	commonUpdateArgs := groupAndChannelArgs[0:]
 	if options.SkipSelfUpdate {
 		commonUpdateArgs = append(commonUpdateArgs, "--self-update=no")
@@ -275,6 +289,8 @@ func Use(group, channel string, shell string, options UseOptions) (err error) {
 
 	if !options.WithGC {
 		commonUpdateArgs = append(commonUpdateArgs, "--with-gc=no")
+	} else {
+		commonUpdateArgs = append(commonUpdateArgs, gcRetentionArgs(options.GCOptions)...)
 	}
 
 	commonUpdateArgs = append(commonUpdateArgs, "--try-trdl=no")
@@ -291,10 +307,10 @@ func Use(group, channel string, shell string, options UseOptions) (err error) {
 	}
 
 	scriptArgs := []interface{}{
-		strings.Join(groupAndChannelArgs, " "),  // %[1]s: group channel
-		strings.Join(foregroundUpdateArgs, " "), // %[2]s: group channel [flag ...]
-		strings.Join(backgroundUpdateArgs, " "), // %[3]s: group channel [flag ...]
-		firstWerfPathLogPath,                    // %[4]s: multiwerf_use_first_werf_path.log
+		quoteShellArgs(shell, groupAndChannelArgs),  // %[1]s: group channel
+		quoteShellArgs(shell, foregroundUpdateArgs), // %[2]s: group channel [flag ...]
+		quoteShellArgs(shell, backgroundUpdateArgs), // %[3]s: group channel [flag ...]
+		firstWerfPathLogPath,                        // %[4]s: multiwerf_use_first_werf_path.log
 	}
 
 	var filename = "werf_source"
@@ -389,7 +405,13 @@ eval "$WERF_FUNC"
 		}
 
 		fileContentBytes := []byte(fileContent)
-		dstPath := filepath.Join(StorageDir, "scripts", strings.Join([]string{group, channel}, "-"), filename)
+		scriptsDirName := strings.Join(groupAndChannelArgs, "-")
+		if selector.IsConstraint() {
+			// the constraint may contain characters that are not allowed in file names
+			scriptsDirName = strings.Join([]string{"constraint", util.MurmurHash(selector.Constraint)}, "-")
+		}
+
+		dstPath := filepath.Join(StorageDir, "scripts", scriptsDirName, filename)
 		tmpDstPath := dstPath + ".tmp"
 
 		if exist, err := FileExists(dstPath); err != nil {
@@ -403,6 +425,10 @@ eval "$WERF_FUNC"
 			}
 
 			if bytes.Equal(currentFileContentBytes, fileContentBytes) {
+				// GC removes scripts that have not been used for a long time
+				now := time.Now()
+				_ = os.Chtimes(dstPath, now, now)
+
 				fmt.Println(dstPath)
 				return nil
 			}
@@ -431,10 +457,22 @@ eval "$WERF_FUNC"
 	return nil
 }
 
-// WerfPath prints path to the actual version available for the group/channel based on local channel mapping
-func WerfPath(group string, channel string, tryTrdlOption bool) (err error) {
-	if tryTrdlOption {
-		logPath := filepath.Join(os.Getenv("HOME"), ".multiwerf", "trdl", "log")
+// trdlLogPath is the log of trdl commands performed by werf-path and werf-exec
+func trdlLogPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".multiwerf", "trdl", "log")
+}
+
+// WerfPath prints path to the actual version available for the group/channel based on local channel mapping or to the exact version
+func WerfPath(groupOrVersion string, channel string, tryTrdlOption bool) (err error) {
+	printer := output.NewSilentPrint()
+
+	selector, err := resolveVersionSelector(groupOrVersion, channel, printer)
+	if err != nil {
+		return err
+	}
+
+	if tryTrdlOption && selector.IsChannel() {
+		logPath := trdlLogPath()
 		if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
 			return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(logPath), err)
 		}
@@ -445,18 +483,12 @@ func WerfPath(group string, channel string, tryTrdlOption bool) (err error) {
 		}
 		defer logWriter.Close()
 
-		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfBinPathCommand(group, channel, os.Stdout, logWriter), false)
+		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfBinPathCommand(selector.Group, selector.Channel, os.Stdout, logWriter), false)
 		if done {
 			return err
 		}
 	}
 
-	printer := output.NewSilentPrint()
-
-	if err := ValidateGroup(group, printer); err != nil {
-		return err
-	}
-
 	if err := SetupStorageDir(printer); err != nil {
 		return err
 	}
@@ -465,7 +497,7 @@ func WerfPath(group string, channel string, tryTrdlOption bool) (err error) {
 	messages := make(chan ActionMessage, 0)
 
 	go func() {
-		binaryInfo = UseChannelVersionBinary(messages, group, channel)
+		binaryInfo = useSelectedVersionBinary(messages, selector)
 		messages <- ActionMessage{action: "exit"}
 	}()
 
@@ -473,15 +505,24 @@ func WerfPath(group string, channel string, tryTrdlOption bool) (err error) {
 		return err
 	}
 
+	binaryInfo.ReleaseInUseLock()
+
 	fmt.Println(binaryInfo.BinaryPath)
 
 	return nil
 }
 
-// WerfExec launches the latest binary version available for the group/channel based on local channel mapping
-func WerfExec(group, channel string, args []string, tryTrdlOption bool) (err error) {
-	if tryTrdlOption {
-		logPath := filepath.Join(os.Getenv("HOME"), ".multiwerf", "trdl", "log")
+// WerfExec launches the latest binary version available for the group/channel based on local channel mapping or the exact version
+func WerfExec(groupOrVersion, channel string, args []string, tryTrdlOption bool) (err error) {
+	printer := output.NewSilentPrint()
+
+	selector, err := resolveVersionSelector(groupOrVersion, channel, printer)
+	if err != nil {
+		return err
+	}
+
+	if tryTrdlOption && selector.IsChannel() {
+		logPath := trdlLogPath()
 		if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err != nil {
 			return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(logPath), err)
 		}
@@ -492,18 +533,12 @@ func WerfExec(group, channel string, args []string, tryTrdlOption bool) (err err
 		}
 		defer logWriter.Close()
 
-		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfExecCommand(group, channel, args, os.Stdout, logWriter), false)
+		done, err := trdlexec.TryExecTrdl(trdlexec.NewTrdlWerfExecCommand(selector.Group, selector.Channel, args, os.Stdout, logWriter), false)
 		if done {
 			return err
 		}
 	}
 
-	printer := output.NewSilentPrint()
-
-	if err := ValidateGroup(group, printer); err != nil {
-		return err
-	}
-
 	if err := SetupStorageDir(printer); err != nil {
 		return err
 	}
@@ -512,7 +547,7 @@ func WerfExec(group, channel string, args []string, tryTrdlOption bool) (err err
 	messages := make(chan ActionMessage, 0)
 
 	go func() {
-		binaryInfo = UseChannelVersionBinary(messages, group, channel)
+		binaryInfo = useSelectedVersionBinary(messages, selector)
 		messages <- ActionMessage{action: "exit"}
 	}()
 
@@ -520,6 +555,9 @@ func WerfExec(group, channel string, args []string, tryTrdlOption bool) (err err
 		return err
 	}
 
+	// GC does not remove the version until werf exits
+	defer binaryInfo.ReleaseInUseLock()
+
 	cmd := exec.Command(binaryInfo.BinaryPath, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -528,26 +566,6 @@ func WerfExec(group, channel string, args []string, tryTrdlOption bool) (err err
 	return cmd.Run()
 }
 
-func ValidateGroup(group string, printer output.Printer) error {
-	messages := make(chan ActionMessage, 0)
-
-	go func() {
-		err := CheckMajorMinor(group)
-		if err != nil {
-			messages <- ActionMessage{err: err}
-		}
-
-		messages <- ActionMessage{
-			msg:   fmt.Sprintf("The group %s is the valid major.minor version", group),
-			debug: true,
-		}
-
-		messages <- ActionMessage{action: "exit"}
-	}()
-
-	return PrintActionMessages(messages, printer)
-}
-
 func SetupStorageDir(printer output.Printer) error {
 	messages := make(chan ActionMessage, 0)
 
@@ -583,18 +601,70 @@ func SetupStorageDir(printer output.Printer) error {
 			}
 		}
 
+		SystemStoreDir = ""
+		if app.SystemStoreDir != "" {
+			systemStoreDir, err := ExpandPath(app.SystemStoreDir)
+			if err != nil {
+				messages <- ActionMessage{
+					err: fmt.Errorf("invalid system store dir %s: %s", app.SystemStoreDir, err),
+				}
+			}
+
+			// GC removes versions from StorageDir and must never touch the system store
+			if filepath.Clean(systemStoreDir) == filepath.Clean(StorageDir) {
+				messages <- ActionMessage{
+					err: fmt.Errorf("the system store dir %s should differ from the storage dir", systemStoreDir),
+				}
+			}
+
+			SystemStoreDir = systemStoreDir
+
+			messages <- ActionMessage{
+				msg:   fmt.Sprintf("system store dir is %s", SystemStoreDir),
+				debug: true,
+			}
+		}
+
 		messages <- ActionMessage{action: "exit"}
 	}()
 
 	return PrintActionMessages(messages, printer)
 }
 
-func GC() error {
+func GC(options GCOptions) error {
 	printer := output.NewSimplePrint(os.Stdout)
 
+	if err := validateGCOptions(options); err != nil {
+		return err
+	}
+
 	if err := SetupStorageDir(printer); err != nil {
 		return err
 	}
 
-	return gc(printer)
+	return gc(printer, options)
+}
+
+var safeShellArgRegexp = regexp.MustCompile(`^[a-zA-Z0-9_./:=+@%,\\-]*$`)
+
+// quoteShellArgs joins args quoting ones with special characters, e.g. constraints like ">=1.2.10 <1.3"
+func quoteShellArgs(shell string, args []string) string {
+	var quotedArgs []string
+	for _, arg := range args {
+		if safeShellArgRegexp.MatchString(arg) {
+			quotedArgs = append(quotedArgs, arg)
+			continue
+		}
+
+		switch shell {
+		case "cmdexe":
+			quotedArgs = append(quotedArgs, `"`+arg+`"`)
+		case "powershell":
+			quotedArgs = append(quotedArgs, "'"+strings.ReplaceAll(arg, "'", "''")+"'")
+		default:
+			quotedArgs = append(quotedArgs, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+		}
+	}
+
+	return strings.Join(quotedArgs, " ")
 }
@@ -0,0 +1,93 @@
+package multiwerf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+const ProjectConfigFilename = ".multiwerf.yaml"
+
+// ProjectConfig pins the project to the group and the channel, to the exact version or to the semver constraint:
+//
+//	group: "1.2"
+//	channel: ea
+//
+// or
+//
+//	version: v1.2.37
+//
+// or
+//
+//	version: ~1.2.30
+type ProjectConfig struct {
+	Group   string `yaml:"group"`
+	Channel string `yaml:"channel"`
+	Version string `yaml:"version"`
+}
+
+// FindProjectConfig looks for the project config in the dir and its parents and returns its path and content.
+// The config is nil if it is not found.
+func FindProjectConfig(dir string) (string, *ProjectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for {
+		configPath := filepath.Join(dir, ProjectConfigFilename)
+		exist, err := FileExists(configPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("file exists failed %s: %s", configPath, err)
+		}
+
+		if exist {
+			config, err := readProjectConfig(configPath)
+			if err != nil {
+				return "", nil, err
+			}
+
+			return configPath, config, nil
+		}
+
+		parentDir := filepath.Dir(dir)
+		if parentDir == dir {
+			return "", nil, nil
+		}
+
+		dir = parentDir
+	}
+}
+
+func readProjectConfig(configPath string) (*ProjectConfig, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read file failed %s: %s", configPath, err)
+	}
+
+	config := &ProjectConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml failed %s: %s", configPath, err)
+	}
+
+	return config, nil
+}
+
+func (c *ProjectConfig) VersionSelector() (*VersionSelector, error) {
+	switch {
+	case c.Version != "" && (c.Group != "" || c.Channel != ""):
+		return nil, fmt.Errorf("version cannot be used with group and channel")
+	case c.Version != "":
+		if !IsExactVersion(c.Version) && !IsVersionConstraint(c.Version) {
+			return nil, fmt.Errorf("version %q should be in form vMAJOR.MINOR.PATCH or the semver constraint", c.Version)
+		}
+
+		return NewVersionSelector(c.Version, "")
+	case c.Group != "":
+		return NewVersionSelector(c.Group, c.Channel)
+	default:
+		return nil, fmt.Errorf("group or version is required")
+	}
+}
@@ -0,0 +1,60 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FindProjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-project-config-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	subDir := filepath.Join(dir, "a", "b")
+	assert.NoError(t, os.MkdirAll(subDir, os.ModePerm))
+
+	configPath, config, err := FindProjectConfig(subDir)
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+	assert.Empty(t, configPath)
+
+	expectedConfigPath := filepath.Join(dir, "a", ProjectConfigFilename)
+	assert.NoError(t, ioutil.WriteFile(expectedConfigPath, []byte("group: 1.10\nchannel: ea\n"), os.ModePerm))
+
+	configPath, config, err = FindProjectConfig(subDir)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedConfigPath, configPath)
+	assert.Equal(t, &ProjectConfig{Group: "1.10", Channel: "ea"}, config)
+
+	selector, err := config.VersionSelector()
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Group: "1.10", Channel: "ea"}, selector)
+
+	assert.NoError(t, ioutil.WriteFile(expectedConfigPath, []byte("chanel: ea\n"), os.ModePerm))
+	_, _, err = FindProjectConfig(subDir)
+	assert.Error(t, err, "unknown fields should be rejected")
+}
+
+func Test_ProjectConfig_VersionSelector(t *testing.T) {
+	selector, err := (&ProjectConfig{Version: "v1.2.37"}).VersionSelector()
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Version: "v1.2.37"}, selector)
+
+	selector, err = (&ProjectConfig{Group: "1.2"}).VersionSelector()
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Group: "1.2", Channel: "stable"}, selector)
+
+	for _, config := range []*ProjectConfig{
+		{},
+		{Version: "v1.2.37", Group: "1.2"},
+		{Version: "1.2"},
+		{Channel: "stable"},
+	} {
+		_, err := config.VersionSelector()
+		assert.Error(t, err)
+	}
+}
@@ -62,14 +62,28 @@ func CalculateSHA256(filePath string) (string, error) {
 // ReleaseFiles return a map with release filenames of package pkg for particular osArch and version
 func ReleaseFiles(pkg string, version string, osArch string) map[string]string {
 	files := map[string]string{
-		"hash": "SHA256SUMS",
-		//"sig":     "SHA256SUMS.sig", // TODO implement goreleaser lifecycle and verify gpg signing
+		"hash":    "SHA256SUMS",
+		"sig":     "SHA256SUMS.sig",
 		"program": ReleaseProgramFilename(pkg, version, osArch),
 	}
 
 	return files
 }
 
+// RequiredReleaseFiles returns release files without the optional signature file
+func RequiredReleaseFiles(files map[string]string) map[string]string {
+	requiredFiles := map[string]string{}
+	for fileType, fileName := range files {
+		if fileType == "sig" {
+			continue
+		}
+
+		requiredFiles[fileType] = fileName
+	}
+
+	return requiredFiles
+}
+
 func ReleaseProgramFilename(pkg, version, osArch string) string {
 	fileExt := ""
 	if strings.Contains(osArch, "windows") {
@@ -81,11 +95,11 @@ func ReleaseProgramFilename(pkg, version, osArch string) string {
 
 func IsReleaseFilesExist(dir string, files map[string]string) (bool, error) {
 	exist := true
-	for _, fileName := range files {
-		// TODO implement goreleaser lifecycle and verify gpg signing
-		// if fileType == "sig" {
-		//	continue
-		// }
+	for fileType, fileName := range files {
+		// the signature is optional and is checked by verifyReleaseSignature
+		if fileType == "sig" {
+			continue
+		}
 
 		fExist, err := FileExists(filepath.Join(dir, fileName))
 		if err != nil {
@@ -189,26 +203,58 @@ func TildeExpand(path string) (string, error) {
 }
 
 func VerifyReleaseFileHash(messages chan ActionMessage, dir string, hashFile string, targetFile string) (bool, error) {
-	if hashFileExists, err := FileExists(filepath.Join(dir, hashFile)); err != nil {
+	hashes, err := loadReleaseFileHashes(messages, dir, hashFile, targetFile)
+	if err != nil || hashes == nil {
+		return false, err
+	}
+
+	return VerifyReleaseFileHashFromHashes(messages, dir, hashes, targetFile)
+}
+
+// VerifyDownloadedReleaseFileHash verifies targetFileHash calculated while downloading of targetFile
+func VerifyDownloadedReleaseFileHash(messages chan ActionMessage, dir string, hashFile string, targetFile string, targetFileHash string) (bool, error) {
+	hashes, err := loadReleaseFileHashes(messages, dir, hashFile, targetFile)
+	if err != nil || hashes == nil {
 		return false, err
+	}
+
+	return VerifyDownloadedReleaseFileHashFromHashes(messages, hashes, targetFile, targetFileHash)
+}
+
+func VerifyReleaseFileHashFromHashes(messages chan ActionMessage, dir string, hashes map[string]string, targetFile string) (bool, error) {
+	return verifyReleaseFileHashFromHashes(messages, hashes, targetFile, func() (string, error) {
+		return CalculateSHA256(filepath.Join(dir, targetFile))
+	})
+}
+
+func VerifyDownloadedReleaseFileHashFromHashes(messages chan ActionMessage, hashes map[string]string, targetFile string, targetFileHash string) (bool, error) {
+	return verifyReleaseFileHashFromHashes(messages, hashes, targetFile, func() (string, error) {
+		return targetFileHash, nil
+	})
+}
+
+// loadReleaseFileHashes returns hashes from hashFile or nil if hashFile or targetFile does not exist
+func loadReleaseFileHashes(messages chan ActionMessage, dir string, hashFile string, targetFile string) (map[string]string, error) {
+	if hashFileExists, err := FileExists(filepath.Join(dir, hashFile)); err != nil {
+		return nil, err
 	} else if !hashFileExists {
 		messages <- ActionMessage{
 			msg:     fmt.Sprintf("The file %s does not exist", hashFile),
 			msgType: WarnMsgType,
 		}
 
-		return false, nil
+		return nil, nil
 	}
 
 	if prgFileExists, err := FileExists(filepath.Join(dir, targetFile)); err != nil {
-		return false, err
+		return nil, err
 	} else if !prgFileExists {
 		messages <- ActionMessage{
 			msg:     fmt.Sprintf("The file %s does not exist", targetFile),
 			msgType: WarnMsgType,
 		}
 
-		return false, nil
+		return nil, nil
 	}
 
 	hashes := LoadHashFile(dir, hashFile)
@@ -218,13 +264,13 @@ func VerifyReleaseFileHash(messages chan ActionMessage, dir string, hashFile str
 			msgType: WarnMsgType,
 		}
 
-		return false, nil
+		return nil, nil
 	}
 
-	return VerifyReleaseFileHashFromHashes(messages, dir, hashes, targetFile)
+	return hashes, nil
 }
 
-func VerifyReleaseFileHashFromHashes(messages chan ActionMessage, dir string, hashes map[string]string, targetFile string) (bool, error) {
+func verifyReleaseFileHashFromHashes(messages chan ActionMessage, hashes map[string]string, targetFile string, targetFileHashFunc func() (string, error)) (bool, error) {
 	hashForFile, hasHash := hashes[targetFile]
 	if !hasHash {
 		messages <- ActionMessage{
@@ -235,7 +281,7 @@ func VerifyReleaseFileHashFromHashes(messages chan ActionMessage, dir string, ha
 		return false, nil
 	}
 
-	hash, err := CalculateSHA256(filepath.Join(dir, targetFile))
+	hash, err := targetFileHashFunc()
 	if err != nil {
 		messages <- ActionMessage{
 			msg:     fmt.Sprintf("sha256 failed for %s: %v", targetFile, err),
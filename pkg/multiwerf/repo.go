@@ -1,23 +1,50 @@
 package multiwerf
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
 	"github.com/werf/multiwerf/pkg/app"
 	"github.com/werf/multiwerf/pkg/repo"
 )
 
-func NewSelfBtClient() (bc repo.Repo) {
-	var repoName string
-	if app.Experimental {
-		repoName = app.SelfExperimentalBintrayRepo
-	} else {
-		repoName = app.SelfBintrayRepo
-	}
+const (
+	S3RepoType      = "s3"
+	BintrayRepoType = "bintray"
+	HTTPRepoType    = "http"
+)
 
-	return repo.NewBintrayClient(app.SelfBintraySubject, repoName, app.SelfBintrayPackage)
+// RepoConfig describes a single backend of the repository chain.
+//
+// An entry without package is used for both werf and multiwerf release files.
+// Entries are tried in order of descending priority, entries with equal priority keep the declaration order.
+//
+// S3 specific fields that are not set are taken from the --s3-* flags.
+type RepoConfig struct {
+	Type     string `json:"type"`
+	Package  string `json:"package,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+
+	Region          string `json:"region,omitempty"`
+	ForcePathStyle  *bool  `json:"force_path_style,omitempty"`
+	ReleasesFolder  string `json:"releases_folder,omitempty"`
+	Credentials     string `json:"credentials,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	Profile         string `json:"profile,omitempty"`
+}
+
+func NewSelfBtClient() (bc repo.Repo) {
+	return repo.NewBintrayClient(app.SelfBintraySubject, selfBintrayRepo(), app.SelfBintrayPackage)
 }
 
 func NewSelfS3Client() (s3c repo.Repo) {
-	return repo.NewS3Client(app.SelfPackageName)
+	return repo.NewS3Client(app.SelfPackageName, defaultS3Options())
 }
 
 func NewAppBtClient() (bc repo.Repo) {
@@ -25,5 +52,225 @@ func NewAppBtClient() (bc repo.Repo) {
 }
 
 func NewAppS3Client() (s3c repo.Repo) {
-	return repo.NewS3Client(app.AppPackageName)
+	return repo.NewS3Client(app.AppPackageName, defaultS3Options())
+}
+
+func NewSelfHTTPClient() (hc repo.Repo) {
+	return repo.NewHTTPClient(app.SelfHTTPRepoUrl, app.HTTPRepoIndexUrlTemplate, app.HTTPRepoFileUrlTemplate)
+}
+
+func NewAppHTTPClient() (hc repo.Repo) {
+	return repo.NewHTTPClient(app.HTTPRepoUrl, app.HTTPRepoIndexUrlTemplate, app.HTTPRepoFileUrlTemplate)
+}
+
+// selfRepoClients returns the repository chain for multiwerf release files
+func selfRepoClients() ([]repo.Repo, error) {
+	configs, err := loadReposConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if configs == nil {
+		var repoClients []repo.Repo
+		if app.SelfHTTPRepoUrl != "" {
+			repoClients = append(repoClients, NewSelfHTTPClient())
+		}
+
+		return append(repoClients, NewSelfS3Client(), NewSelfBtClient()), nil
+	}
+
+	return newRepoClients(app.SelfPackageName, configs)
+}
+
+// appRepoClients returns the repository chain for werf release files
+func appRepoClients() ([]repo.Repo, error) {
+	configs, err := loadReposConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if configs == nil {
+		var repoClients []repo.Repo
+		if app.HTTPRepoUrl != "" {
+			repoClients = append(repoClients, NewAppHTTPClient())
+		}
+
+		return append(repoClients, NewAppS3Client(), NewAppBtClient()), nil
+	}
+
+	return newRepoClients(app.AppPackageName, configs)
+}
+
+// loadReposConfig returns the repository chain declared with --repos or --repos-config.
+// Nil is returned if the chain is not declared and the default one should be used.
+func loadReposConfig() ([]RepoConfig, error) {
+	var data []byte
+	switch {
+	case app.Repos != "" && app.ReposConfigPath != "":
+		return nil, fmt.Errorf("only one of --repos and --repos-config can be specified")
+	case app.Repos != "":
+		data = []byte(app.Repos)
+	case app.ReposConfigPath != "":
+		path, err := ExpandPath(app.ReposConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repos config path %s: %s", app.ReposConfigPath, err)
+		}
+
+		data, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read file failed: %s", err)
+		}
+	default:
+		return nil, nil
+	}
+
+	configs, err := parseReposConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repos config: %s", err)
+	}
+
+	return configs, nil
+}
+
+func parseReposConfig(data []byte) ([]RepoConfig, error) {
+	configs := []RepoConfig{}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("unmarshal json failed: %s", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no repositories declared")
+	}
+
+	for ind, config := range configs {
+		switch config.Type {
+		case S3RepoType:
+			if err := repo.ValidateS3Credentials(config.s3Options()); err != nil {
+				return nil, fmt.Errorf("repository #%d: %s", ind, err)
+			}
+		case BintrayRepoType:
+			if config.Endpoint != "" {
+				return nil, fmt.Errorf("repository #%d: endpoint is not supported for %s repository", ind, config.Type)
+			}
+		case HTTPRepoType:
+			if config.Endpoint == "" {
+				return nil, fmt.Errorf("repository #%d: endpoint is required for %s repository", ind, config.Type)
+			}
+		default:
+			return nil, fmt.Errorf("repository #%d: unknown type %q, expected %s, %s or %s", ind, config.Type, S3RepoType, BintrayRepoType, HTTPRepoType)
+		}
+
+		switch config.Package {
+		case "", app.AppPackageName, app.SelfPackageName:
+		default:
+			return nil, fmt.Errorf("repository #%d: unknown package %q, expected %s or %s", ind, config.Package, app.AppPackageName, app.SelfPackageName)
+		}
+	}
+
+	return configs, nil
+}
+
+// newRepoClients builds the repository chain for the package pkg
+func newRepoClients(pkg string, configs []RepoConfig) ([]repo.Repo, error) {
+	var pkgConfigs []RepoConfig
+	for _, config := range configs {
+		if config.Package == "" || config.Package == pkg {
+			pkgConfigs = append(pkgConfigs, config)
+		}
+	}
+
+	if len(pkgConfigs) == 0 {
+		return nil, fmt.Errorf("no repositories declared for package %s", pkg)
+	}
+
+	sort.SliceStable(pkgConfigs, func(i, j int) bool {
+		return pkgConfigs[i].Priority > pkgConfigs[j].Priority
+	})
+
+	var repoClients []repo.Repo
+	for _, config := range pkgConfigs {
+		repoClients = append(repoClients, newRepoClient(pkg, config))
+	}
+
+	return repoClients, nil
+}
+
+func newRepoClient(pkg string, config RepoConfig) repo.Repo {
+	switch config.Type {
+	case S3RepoType:
+		bucket := config.Bucket
+		if bucket == "" {
+			bucket = pkg
+		}
+
+		return repo.NewS3Client(bucket, config.s3Options())
+	case BintrayRepoType:
+		if pkg == app.SelfPackageName {
+			bucket := config.Bucket
+			if bucket == "" {
+				bucket = selfBintrayRepo()
+			}
+
+			return repo.NewBintrayClient(app.SelfBintraySubject, bucket, app.SelfBintrayPackage)
+		}
+
+		bucket := config.Bucket
+		if bucket == "" {
+			bucket = app.BintrayRepo
+		}
+
+		return repo.NewBintrayClient(app.BintraySubject, bucket, app.BintrayPackage)
+	case HTTPRepoType:
+		baseUrl := strings.ReplaceAll(config.Endpoint, "{package}", pkg)
+		return repo.NewHTTPClient(baseUrl, app.HTTPRepoIndexUrlTemplate, app.HTTPRepoFileUrlTemplate)
+	default:
+		panic(fmt.Sprintf("unknown repository type %q", config.Type))
+	}
+}
+
+func selfBintrayRepo() string {
+	if app.Experimental {
+		return app.SelfExperimentalBintrayRepo
+	}
+
+	return app.SelfBintrayRepo
+}
+
+// defaultS3Options returns S3 options from the --s3-* flags
+func defaultS3Options() repo.S3Options {
+	return repo.S3Options{
+		Endpoint:        app.S3Endpoint,
+		Region:          app.S3Region,
+		ForcePathStyle:  app.S3ForcePathStyle,
+		ReleasesFolder:  app.S3ReleasesFolder,
+		Credentials:     app.S3Credentials,
+		AccessKeyID:     app.S3AccessKeyID,
+		SecretAccessKey: app.S3SecretAccessKey,
+		Profile:         app.S3Profile,
+	}
+}
+
+func (c RepoConfig) s3Options() repo.S3Options {
+	options := defaultS3Options()
+
+	if c.Endpoint != "" {
+		options.Endpoint = c.Endpoint
+	}
+	if c.Region != "" {
+		options.Region = c.Region
+	}
+	if c.ForcePathStyle != nil {
+		options.ForcePathStyle = *c.ForcePathStyle
+	}
+	if c.ReleasesFolder != "" {
+		options.ReleasesFolder = c.ReleasesFolder
+	}
+	if c.Credentials != "" {
+		options.Credentials = c.Credentials
+		options.AccessKeyID = c.AccessKeyID
+		options.SecretAccessKey = c.SecretAccessKey
+		options.Profile = c.Profile
+	}
+
+	return options
 }
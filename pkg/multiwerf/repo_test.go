@@ -0,0 +1,55 @@
+package multiwerf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseReposConfig(t *testing.T) {
+	configs, err := parseReposConfig([]byte(`[{"type": "s3", "bucket": "mirror"}, {"type": "http", "endpoint": "https://mirror.example.com/{package}"}]`))
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+
+	for _, data := range []string{
+		`[]`,
+		`[{"type": "ftp"}]`,
+		`[{"type": "http"}]`,
+		`[{"type": "bintray", "endpoint": "https://dl.example.com"}]`,
+		`[{"type": "s3", "package": "helm"}]`,
+		`{"type": "s3"}`,
+	} {
+		_, err := parseReposConfig([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func Test_newRepoClients(t *testing.T) {
+	configs := []RepoConfig{
+		{Type: S3RepoType},
+		{Type: BintrayRepoType, Package: "multiwerf"},
+		{Type: HTTPRepoType, Endpoint: "https://mirror.example.com/{package}", Priority: 10},
+		{Type: S3RepoType, Package: "werf", Priority: 10},
+	}
+
+	repoClients, err := newRepoClients("werf", configs)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, repoClient := range repoClients {
+		names = append(names, repoClient.String())
+	}
+	assert.Equal(t, []string{"http", "s3", "s3"}, names)
+
+	repoClients, err = newRepoClients("multiwerf", configs)
+	assert.NoError(t, err)
+
+	names = nil
+	for _, repoClient := range repoClients {
+		names = append(names, repoClient.String())
+	}
+	assert.Equal(t, []string{"http", "s3", "bintray"}, names)
+
+	_, err = newRepoClients("multiwerf", []RepoConfig{{Type: S3RepoType, Package: "werf"}})
+	assert.Error(t, err)
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/werf/multiwerf/pkg/app"
 	"github.com/werf/multiwerf/pkg/locker"
 	"github.com/werf/multiwerf/pkg/output"
-	"github.com/werf/multiwerf/pkg/repo"
+	"github.com/werf/multiwerf/pkg/pgp"
 	"github.com/werf/multiwerf/pkg/util"
 )
 
@@ -161,13 +161,27 @@ func doSelfUpdate(messages chan ActionMessage) string {
 	selfDir := filepath.Dir(selfPath)
 	selfName := filepath.Base(selfPath)
 
-	repoClients := []repo.Repo{
-		NewSelfS3Client(),
-		NewSelfBtClient(),
+	repoClients, err := selfRepoClients()
+	if err != nil {
+		messages <- ActionMessage{
+			comment: "self update error",
+			msg:     fmt.Sprintf("Self-update: Repositories configuration error: %v", err),
+			msgType: FailMsgType,
+			stage:   "self-update"}
+		return ""
 	}
 
 	var files, downloadFiles map[string]string
 	var latestVersion string
+
+	// the downloaded binary and its partial file are removed if it is not verified or not installed
+	removeDownloadedFiles := func() {
+		for _, fileName := range downloadFiles {
+			_ = os.Remove(filepath.Join(selfDir, fileName))
+			_ = os.Remove(util.PartialFilePath(filepath.Join(selfDir, fileName)))
+		}
+	}
+
 	for ind, repoClient := range repoClients {
 		shouldIgnoreError := len(repoClients) > ind+1
 
@@ -252,6 +266,26 @@ func doSelfUpdate(messages chan ActionMessage) string {
 			msgType: OkMsgType,
 			stage:   "self-update"}
 
+		// the key is checked only if there is the version to update to, builds without the pinned key work as usual
+		if app.SelfSigningKey == "" {
+			messages <- ActionMessage{
+				comment: "self update warning",
+				msg:     "Skip Self-update: The signing key is not pinned in this build, update multiwerf manually",
+				msgType: WarnMsgType,
+				stage:   "self-update"}
+			return ""
+		}
+
+		keyring, err := selfSigningKeyring()
+		if err != nil {
+			messages <- ActionMessage{
+				comment: "self update error",
+				msg:     fmt.Sprintf("Self-update: Signing key error: %v", err),
+				msgType: FailMsgType,
+				stage:   "self-update"}
+			return ""
+		}
+
 		files = ReleaseFiles(app.SelfPackageName, latestVersion, app.OsArch)
 		downloadFiles = map[string]string{
 			"program": files["program"],
@@ -262,10 +296,11 @@ func doSelfUpdate(messages chan ActionMessage) string {
 
 		messages <- ActionMessage{msg: "Self-update: Downloading ...", debug: true}
 
-		err = repoClient.DownloadFiles(latestVersion, selfDir, downloadFiles)
+		downloadedHashes, err := repoClient.DownloadFiles(latestVersion, selfDir, downloadFiles, downloadOptions(messages))
 		if err != nil {
 			msg := fmt.Sprintf("Self-update: Download release error: %v", err)
 			sendMessageFunc(msg)
+			removeDownloadedFiles()
 			if shouldIgnoreError {
 				continue
 			}
@@ -273,11 +308,36 @@ func doSelfUpdate(messages chan ActionMessage) string {
 			return ""
 		}
 
-		// TODO add hash verification!
 		sha256sums, err := repoClient.GetFileContent(latestVersion, files["hash"])
 		if err != nil {
 			msg := fmt.Sprintf("Self-update: Download %s error: %v", files["hash"], err)
 			sendMessageFunc(msg)
+			removeDownloadedFiles()
+			if shouldIgnoreError {
+				continue
+			}
+
+			return ""
+		}
+
+		// the checksum file must be signed with the pinned key
+		sha256sumsSig, err := repoClient.GetFileContent(latestVersion, files["sig"])
+		if err != nil {
+			msg := fmt.Sprintf("Self-update: Download %s error: %v", files["sig"], err)
+			sendMessageFunc(msg)
+			removeDownloadedFiles()
+			if shouldIgnoreError {
+				continue
+			}
+
+			return ""
+		}
+
+		signer, err := pgp.CheckDetachedSignature(keyring, strings.NewReader(sha256sums), []byte(sha256sumsSig))
+		if err != nil {
+			msg := fmt.Sprintf("Self-update: %s signature verification error: %v", files["hash"], err)
+			sendMessageFunc(msg)
+			removeDownloadedFiles()
 			if shouldIgnoreError {
 				continue
 			}
@@ -285,12 +345,17 @@ func doSelfUpdate(messages chan ActionMessage) string {
 			return ""
 		}
 
-		// check hash of local binary
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("Self-update: %s is signed by %s", files["hash"], strings.Join(pgp.SignerNames(signer), ", ")),
+			debug: true}
+
+		// check hash of the downloaded binary calculated while downloading
 		hashes := LoadHashMap(strings.NewReader(sha256sums))
-		match, err := VerifyReleaseFileHashFromHashes(messages, selfDir, hashes, files["program"])
+		match, err := VerifyDownloadedReleaseFileHashFromHashes(messages, hashes, files["program"], downloadedHashes[files["program"]])
 		if err != nil {
 			msg := fmt.Sprintf("Self-update: %s hash verification error: %v", files["program"], err)
 			sendMessageFunc(msg)
+			removeDownloadedFiles()
 			if shouldIgnoreError {
 				continue
 			}
@@ -300,6 +365,7 @@ func doSelfUpdate(messages chan ActionMessage) string {
 		if !match {
 			msg := fmt.Sprintf("Self-update: %s hash is not verified", files["program"])
 			sendMessageFunc(msg)
+			removeDownloadedFiles()
 			if shouldIgnoreError {
 				continue
 			}
@@ -318,6 +384,7 @@ func doSelfUpdate(messages chan ActionMessage) string {
 			msg:     fmt.Sprintf("Self-update: Chmod 755 failed for %s: %v", files["program"], err),
 			msgType: FailMsgType,
 			stage:   "self-update"}
+		removeDownloadedFiles()
 		return ""
 	}
 
@@ -328,6 +395,7 @@ func doSelfUpdate(messages chan ActionMessage) string {
 			msg:     fmt.Sprintf("Self-update: Replace executable error: %v", err),
 			msgType: FailMsgType,
 			stage:   "self-update"}
+		removeDownloadedFiles()
 		return ""
 	}
 
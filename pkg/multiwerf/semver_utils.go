@@ -2,7 +2,9 @@ package multiwerf
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver"
 )
@@ -38,3 +40,57 @@ func HighestSemverVersion(versions []string) (string, error) {
 	}
 	return "", nil
 }
+
+// HighestSemverVersionByConstraint returns the latest version from versions that matches the constraint
+// or an empty string if there is no such version. Versions that cannot be parsed are skipped.
+func HighestSemverVersionByConstraint(versions []string, constraint string) (string, error) {
+	c, err := newSemverConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("parse constraint %s error: %v", constraint, err)
+	}
+
+	var latest *semver.Version
+	for _, r := range versions {
+		v, err := semver.NewVersion(r)
+		if err != nil {
+			continue
+		}
+
+		if c.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return latest.Original(), nil
+}
+
+// spaceSeparatedConstraintRegexp matches the space between AND constraints, e.g. ">=1.2.10 <1.3"
+var spaceSeparatedConstraintRegexp = regexp.MustCompile(`([0-9a-zA-Z*])\s+([<>=~^!])`)
+
+// newSemverConstraint parses the constraint and also accepts space separated AND constraints
+func newSemverConstraint(constraint string) (*semver.Constraints, error) {
+	return semver.NewConstraint(spaceSeparatedConstraintRegexp.ReplaceAllString(strings.TrimSpace(constraint), "$1, $2"))
+}
+
+// sortVersions sorts versions in ascending semver order, versions that cannot be parsed go first in lexical order
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, errI := semver.NewVersion(versions[i])
+		vj, errJ := semver.NewVersion(versions[j])
+
+		switch {
+		case errI != nil && errJ != nil:
+			return versions[i] < versions[j]
+		case errI != nil:
+			return true
+		case errJ != nil:
+			return false
+		default:
+			return vi.LessThan(vj)
+		}
+	})
+}
@@ -26,3 +26,32 @@ func Test_HighestSemverVersion(t *testing.T) {
 
 	assert.Equal(t, "0.0.1+test.ci.4", version)
 }
+
+func Test_sortVersions(t *testing.T) {
+	versions := []string{"v1.10.0", "v1.2.0", "v1.2.0-alpha.1", "vfoo", "v0.9.1"}
+
+	sortVersions(versions)
+
+	assert.Equal(t, []string{"vfoo", "v0.9.1", "v1.2.0-alpha.1", "v1.2.0", "v1.10.0"}, versions)
+}
+
+func Test_HighestSemverVersionByConstraint(t *testing.T) {
+	input := []string{"v1.2.9", "v1.2.30", "v1.2.31", "v1.2.32-alpha.1", "v1.3.0", "invalid"}
+
+	for constraint, expected := range map[string]string{
+		"~1.2.30":       "v1.2.31",
+		">=1.2.10 <1.3": "v1.2.31",
+		"1.2.x":         "v1.2.31",
+		"^1":            "v1.3.0",
+		">=1.2.32-0":    "v1.3.0",
+		"=1.2.9":        "v1.2.9",
+		"~1.4":          "",
+	} {
+		version, err := HighestSemverVersionByConstraint(input, constraint)
+		assert.NoError(t, err, constraint)
+		assert.Equal(t, expected, version, constraint)
+	}
+
+	_, err := HighestSemverVersionByConstraint(input, "~a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package multiwerf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/pgp"
+)
+
+// trustedKeyring returns keys from --trusted-keyring file or nil if the option is not set
+func trustedKeyring() (openpgp.EntityList, error) {
+	if app.TrustedKeyringPath == "" {
+		return nil, nil
+	}
+
+	path, err := ExpandPath(app.TrustedKeyringPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted keyring path %s: %s", app.TrustedKeyringPath, err)
+	}
+
+	return pgp.ReadKeyRingFile(path)
+}
+
+// selfSigningKeyring returns the key that multiwerf releases must be signed with.
+// The key is pinned at build time and cannot be overridden.
+func selfSigningKeyring() (openpgp.EntityList, error) {
+	if app.SelfSigningKey == "" {
+		return nil, fmt.Errorf("the signing key is not pinned in this build")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(app.SelfSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the pinned signing key: %s", err)
+	}
+
+	keyring, err := pgp.ReadKeyRing(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the pinned signing key: %s", err)
+	}
+
+	return keyring, nil
+}
+
+// verifyReleaseSignature checks the detached signature sigFile of hashFile in dir with the trusted keyring.
+//
+// The check is skipped if the trusted keyring is not set and an unsigned release is accepted with a warning.
+// In both cases strict mode (--require-signatures) fails verification instead.
+// Error is returned only for configuration problems, false is returned if the release is not verified.
+func verifyReleaseSignature(messages chan ActionMessage, dir string, hashFile string, sigFile string) (bool, error) {
+	keyring, err := trustedKeyring()
+	if err != nil {
+		return false, err
+	}
+
+	if keyring == nil {
+		if app.RequireSignatures {
+			return false, fmt.Errorf("the trusted keyring should be set with --trusted-keyring to verify release signatures")
+		}
+
+		messages <- ActionMessage{
+			msg:   "Release signature verification is skipped: the trusted keyring is not set",
+			debug: true,
+		}
+
+		return true, nil
+	}
+
+	if sigFileExists, err := FileExists(filepath.Join(dir, sigFile)); err != nil {
+		return false, err
+	} else if !sigFileExists {
+		if app.RequireSignatures {
+			messages <- ActionMessage{
+				msg:     fmt.Sprintf("The release is not signed: the file %s does not exist", sigFile),
+				msgType: WarnMsgType,
+			}
+
+			return false, nil
+		}
+
+		messages <- ActionMessage{
+			msg:     fmt.Sprintf("The release is not signed: the file %s does not exist, signature verification is skipped", sigFile),
+			msgType: WarnMsgType,
+		}
+
+		return true, nil
+	}
+
+	signer, err := pgp.CheckDetachedSignatureFile(keyring, filepath.Join(dir, hashFile), filepath.Join(dir, sigFile))
+	if err != nil {
+		messages <- ActionMessage{
+			msg:     fmt.Sprintf("The signature %s of %s is not valid: %s", sigFile, hashFile, err),
+			msgType: WarnMsgType,
+		}
+
+		return false, nil
+	}
+
+	messages <- ActionMessage{
+		msg:   fmt.Sprintf("The file %s is signed by %s", hashFile, strings.Join(pgp.SignerNames(signer), ", ")),
+		debug: true,
+	}
+
+	return true, nil
+}
+
+// verifyChannelMappingSignature checks the detached signature <channelMappingUrl>.sig of the channel mapping data
+// with the trusted keyring and returns signer names.
+//
+// The check is skipped if the trusted keyring is not set or the signature does not exist,
+// strict mode (--require-channel-mapping-signature) fails verification instead.
+func verifyChannelMappingSignature(client *http.Client, channelMappingUrl string, data []byte) ([]string, error) {
+	keyring, err := trustedKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring == nil {
+		if app.RequireChannelMappingSignature {
+			return nil, fmt.Errorf("the trusted keyring should be set with --trusted-keyring to verify the channel mapping signature")
+		}
+
+		return nil, nil
+	}
+
+	sigUrl := ChannelMappingSignatureUrl(channelMappingUrl)
+	signature, err := getChannelMappingSignature(client, sigUrl)
+	if err != nil {
+		return nil, fmt.Errorf("get the channel mapping signature from %s failed: %s", sigUrl, err)
+	}
+
+	if signature == nil {
+		if app.RequireChannelMappingSignature {
+			return nil, fmt.Errorf("the channel mapping is not signed: the signature %s is not found", sigUrl)
+		}
+
+		return nil, nil
+	}
+
+	signer, err := pgp.CheckDetachedSignature(keyring, bytes.NewReader(data), signature)
+	if err != nil {
+		return nil, fmt.Errorf("the channel mapping signature %s is not valid: %s", sigUrl, err)
+	}
+
+	return pgp.SignerNames(signer), nil
+}
+
+// ChannelMappingSignatureUrl returns the URL of the detached signature of the channel mapping
+func ChannelMappingSignatureUrl(channelMappingUrl string) string {
+	return channelMappingUrl + ".sig"
+}
+
+// getChannelMappingSignature returns nil if the signature is not found
+func getChannelMappingSignature(client *http.Client, sigUrl string) ([]byte, error) {
+	resp, err := client.Get(sigUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected response status code %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("respBody read failed: %s", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,91 @@
+package multiwerf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/werf/multiwerf/pkg/app"
+)
+
+func Test_verifyChannelMappingSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-signature-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(trustedKeyringPath string, requireChannelMappingSignature bool) {
+		app.TrustedKeyringPath = trustedKeyringPath
+		app.RequireChannelMappingSignature = requireChannelMappingSignature
+	}(app.TrustedKeyringPath, app.RequireChannelMappingSignature)
+
+	signer, err := openpgp.NewEntity("multiwerf", "test", "multiwerf@example.com", nil)
+	assert.NoError(t, err)
+
+	other, err := openpgp.NewEntity("other", "test", "other@example.com", nil)
+	assert.NoError(t, err)
+
+	var keyring bytes.Buffer
+	assert.NoError(t, signer.Serialize(&keyring))
+	keyringPath := filepath.Join(dir, "keyring.gpg")
+	assert.NoError(t, ioutil.WriteFile(keyringPath, keyring.Bytes(), 0644))
+
+	data := []byte(`{"multiwerf": [{"group": "1.1", "channels": [{"name": "stable", "version": "v1.1.0"}]}]}`)
+
+	var signature, otherSignature bytes.Buffer
+	assert.NoError(t, openpgp.DetachSign(&signature, signer, bytes.NewReader(data), nil))
+	assert.NoError(t, openpgp.DetachSign(&otherSignature, other, bytes.NewReader(data), nil))
+
+	files := map[string][]byte{
+		"/signed.json.sig":       signature.Bytes(),
+		"/foreign-key.json.sig":  otherSignature.Bytes(),
+		"/server-error.json.sig": nil,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sig, ok := files[r.URL.Path]
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+		case sig == nil:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = w.Write(sig)
+		}
+	}))
+	defer server.Close()
+
+	for _, require := range []bool{false, true} {
+		app.RequireChannelMappingSignature = require
+
+		app.TrustedKeyringPath = ""
+		signedBy, err := verifyChannelMappingSignature(server.Client(), server.URL+"/signed.json", data)
+		assert.Nil(t, signedBy)
+		assert.Equal(t, require, err != nil, "the trusted keyring is required in strict mode")
+
+		app.TrustedKeyringPath = keyringPath
+
+		signedBy, err = verifyChannelMappingSignature(server.Client(), server.URL+"/signed.json", data)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"multiwerf (test) <multiwerf@example.com>"}, signedBy)
+
+		_, err = verifyChannelMappingSignature(server.Client(), server.URL+"/signed.json", append(data, '\n'))
+		assert.Error(t, err, "the modified channel mapping should be rejected")
+
+		_, err = verifyChannelMappingSignature(server.Client(), server.URL+"/foreign-key.json", data)
+		assert.Error(t, err, "the channel mapping signed with the untrusted key should be rejected")
+
+		_, err = verifyChannelMappingSignature(server.Client(), server.URL+"/server-error.json", data)
+		assert.Error(t, err)
+
+		signedBy, err = verifyChannelMappingSignature(server.Client(), server.URL+"/unsigned.json", data)
+		assert.Nil(t, signedBy)
+		assert.Equal(t, require, err != nil, "the unsigned channel mapping should be rejected only in strict mode")
+	}
+}
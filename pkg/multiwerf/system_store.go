@@ -0,0 +1,199 @@
+package multiwerf
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+	"github.com/werf/multiwerf/pkg/util"
+)
+
+const SystemStoreIndexLockName = "system-store"
+
+// systemStoreIndex keeps versions of the system store verified by the current user,
+// so hashes and signatures of the system store files are not checked on every use
+type systemStoreIndex struct {
+	Versions map[string]systemStoreRecord `json:"versions"`
+}
+
+// systemStoreRecord is valid while the release files are not changed and the verification options are the same.
+// The keyring can be replaced at the same path, so its content hash is kept as well.
+type systemStoreRecord struct {
+	Files                map[string]fileStamp `json:"files"`
+	TrustedKeyring       string               `json:"trusted_keyring"`
+	TrustedKeyringSHA256 string               `json:"trusted_keyring_sha256,omitempty"`
+	RequireSignatures    bool                 `json:"require_signatures"`
+}
+
+// fileStamp is the size and modification time of the file, the missing file has the zero stamp
+type fileStamp struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mod_time"`
+}
+
+func newSystemStoreIndex() *systemStoreIndex {
+	return &systemStoreIndex{Versions: map[string]systemStoreRecord{}}
+}
+
+func systemStoreIndexPath() string {
+	return filepath.Join(StorageDir, "system_store.json")
+}
+
+// readSystemStoreIndex returns the empty index if the system store index does not exist
+func readSystemStoreIndex() (*systemStoreIndex, error) {
+	index := newSystemStoreIndex()
+
+	path := systemStoreIndexPath()
+	if exist, err := FileExists(path); err != nil {
+		return nil, fmt.Errorf("file exists failed %s: %s", path, err)
+	} else if !exist {
+		return index, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file failed %s: %s", path, err)
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("unable to parse system store index %s: %s", path, err)
+	}
+
+	if index.Versions == nil {
+		index.Versions = map[string]systemStoreRecord{}
+	}
+
+	return index, nil
+}
+
+func writeSystemStoreIndex(index *systemStoreIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := util.CreateAtomicFile(systemStoreIndexPath())
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Discard()
+		return fmt.Errorf("write file failed %s: %s", systemStoreIndexPath(), err)
+	}
+
+	_, err = file.Commit()
+	return err
+}
+
+// updateSystemStoreIndex modifies the system store index under the lock, the broken index is replaced with the empty one
+func updateSystemStoreIndex(modify func(index *systemStoreIndex)) error {
+	_, lockHandle, err := locker.Locker.Acquire(SystemStoreIndexLockName, lockgate.AcquireOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to acquire a lock %s: %s", SystemStoreIndexLockName, err)
+	}
+	defer func() { _ = locker.Locker.Release(lockHandle) }()
+
+	index, err := readSystemStoreIndex()
+	if err != nil {
+		index = newSystemStoreIndex()
+	}
+
+	modify(index)
+
+	return writeSystemStoreIndex(index)
+}
+
+// newSystemStoreRecord stamps release files of the version in the system store with the current verification options
+func newSystemStoreRecord(version string) (systemStoreRecord, error) {
+	record := systemStoreRecord{
+		Files:             map[string]fileStamp{},
+		TrustedKeyring:    app.TrustedKeyringPath,
+		RequireSignatures: app.RequireSignatures,
+	}
+
+	if app.TrustedKeyringPath != "" {
+		path, err := ExpandPath(app.TrustedKeyringPath)
+		if err != nil {
+			return systemStoreRecord{}, fmt.Errorf("invalid trusted keyring path %s: %s", app.TrustedKeyringPath, err)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return systemStoreRecord{}, fmt.Errorf("read file failed %s: %s", path, err)
+		}
+
+		record.TrustedKeyringSHA256 = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+
+	for _, fileName := range ReleaseFiles(app.AppPackageName, version, app.OsArch) {
+		stat, err := os.Stat(filepath.Join(systemStoreVersionDirPath(version), fileName))
+		if os.IsNotExist(err) {
+			record.Files[fileName] = fileStamp{}
+			continue
+		} else if err != nil {
+			return systemStoreRecord{}, err
+		}
+
+		record.Files[fileName] = fileStamp{Size: stat.Size(), ModTime: stat.ModTime().UnixNano()}
+	}
+
+	return record, nil
+}
+
+func (r systemStoreRecord) Equal(other systemStoreRecord) bool {
+	if r.TrustedKeyring != other.TrustedKeyring || r.TrustedKeyringSHA256 != other.TrustedKeyringSHA256 ||
+		r.RequireSignatures != other.RequireSignatures || len(r.Files) != len(other.Files) {
+		return false
+	}
+
+	for fileName, stamp := range r.Files {
+		if otherStamp, ok := other.Files[fileName]; !ok || otherStamp != stamp {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSystemStoreVersionVerified returns true if the version has been verified with the same record,
+// i.e. release files have not been changed since
+func isSystemStoreVersionVerified(version string, record systemStoreRecord) (bool, error) {
+	index, err := readSystemStoreIndex()
+	if err != nil {
+		return false, err
+	}
+
+	verifiedRecord, ok := index.Versions[version]
+	if !ok {
+		return false, nil
+	}
+
+	return record.Equal(verifiedRecord), nil
+}
+
+// setSystemStoreVersionVerified records the version verified with the record taken before the verification
+// or forgets the version if the record is nil.
+// The error is not critical for the caller, the version is verified again on the next use.
+func setSystemStoreVersionVerified(messages chan ActionMessage, version string, record *systemStoreRecord) {
+	err := updateSystemStoreIndex(func(index *systemStoreIndex) {
+		if record != nil {
+			index.Versions[version] = *record
+		} else {
+			delete(index.Versions, version)
+		}
+	})
+	if err != nil {
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("Unable to update system store index: %s", err),
+			debug: true,
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package multiwerf
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+func Test_systemStoreBinaryInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-system-store-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir, systemStoreDir string) {
+		StorageDir = storageDir
+		SystemStoreDir = systemStoreDir
+	}(StorageDir, SystemStoreDir)
+	StorageDir = filepath.Join(dir, "storage")
+	SystemStoreDir = filepath.Join(dir, "system_store")
+	assert.NoError(t, locker.Init(filepath.Join(StorageDir, "locks")))
+
+	defer func(trustedKeyringPath string, requireSignatures bool) {
+		app.TrustedKeyringPath = trustedKeyringPath
+		app.RequireSignatures = requireSignatures
+	}(app.TrustedKeyringPath, app.RequireSignatures)
+	app.TrustedKeyringPath = ""
+	app.RequireSignatures = false
+
+	version := "v1.1.0"
+	files := ReleaseFiles(app.AppPackageName, version, app.OsArch)
+	versionDir := systemStoreVersionDirPath(version)
+	assert.NoError(t, os.MkdirAll(versionDir, 0755))
+
+	program := []byte("werf")
+	sha256sums := []byte(fmt.Sprintf("%x  %s\n", sha256.Sum256(program), files["program"]))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, files["program"]), program, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, files["hash"]), sha256sums, 0644))
+
+	messages := make(chan ActionMessage, 100)
+
+	binInfo := systemStoreBinaryInfo(messages, version, false)
+	if assert.NotNil(t, binInfo, "the valid version should be verified on the first use") {
+		assert.True(t, binInfo.HashVerified)
+		assert.Equal(t, filepath.Join(versionDir, files["program"]), binInfo.BinaryPath)
+	}
+
+	index, err := readSystemStoreIndex()
+	assert.NoError(t, err)
+	assert.Contains(t, index.Versions, version)
+
+	// the same size and modification time, the recorded result is used without verification
+	hashPath := filepath.Join(versionDir, files["hash"])
+	stat, err := os.Stat(hashPath)
+	assert.NoError(t, err)
+	corrupted := []byte(fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("nope")), files["program"]))
+	assert.NoError(t, ioutil.WriteFile(hashPath, corrupted, 0644))
+	assert.NoError(t, os.Chtimes(hashPath, stat.ModTime(), stat.ModTime()))
+
+	assert.NotNil(t, systemStoreBinaryInfo(messages, version, false), "the recorded result should be used")
+	assert.Nil(t, systemStoreBinaryInfo(messages, version, true), "the version should be verified again on update")
+
+	index, err = readSystemStoreIndex()
+	assert.NoError(t, err)
+	assert.NotContains(t, index.Versions, version, "the corrupted version should be forgotten")
+
+	// the changed file is verified again
+	assert.NoError(t, ioutil.WriteFile(hashPath, sha256sums, 0644))
+	assert.NoError(t, os.Chtimes(hashPath, time.Now(), time.Now().Add(time.Minute)))
+	assert.NotNil(t, systemStoreBinaryInfo(messages, version, false))
+
+	assert.NoError(t, ioutil.WriteFile(hashPath, corrupted, 0644))
+	assert.NoError(t, os.Chtimes(hashPath, time.Now(), time.Now().Add(2*time.Minute)))
+	assert.Nil(t, systemStoreBinaryInfo(messages, version, false), "the changed version should be verified again")
+
+	assert.Nil(t, systemStoreBinaryInfo(messages, "v1.2.0", false), "the version is not in the system store")
+}
+
+func Test_newSystemStoreRecord_TrustedKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-system-store-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(systemStoreDir string) { SystemStoreDir = systemStoreDir }(SystemStoreDir)
+	SystemStoreDir = filepath.Join(dir, "system_store")
+
+	defer func(trustedKeyringPath string) { app.TrustedKeyringPath = trustedKeyringPath }(app.TrustedKeyringPath)
+	app.TrustedKeyringPath = filepath.Join(dir, "keyring.gpg")
+
+	assert.NoError(t, ioutil.WriteFile(app.TrustedKeyringPath, []byte("key"), 0644))
+	record, err := newSystemStoreRecord("v1.1.0")
+	assert.NoError(t, err)
+
+	sameRecord, err := newSystemStoreRecord("v1.1.0")
+	assert.NoError(t, err)
+	assert.True(t, record.Equal(sameRecord))
+
+	assert.NoError(t, ioutil.WriteFile(app.TrustedKeyringPath, []byte("another key"), 0644))
+	replacedKeyringRecord, err := newSystemStoreRecord("v1.1.0")
+	assert.NoError(t, err)
+	assert.False(t, record.Equal(replacedKeyringRecord), "the keyring replaced at the same path should invalidate the record")
+
+	assert.NoError(t, os.Remove(app.TrustedKeyringPath))
+	_, err = newSystemStoreRecord("v1.1.0")
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package multiwerf
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,8 +9,8 @@ import (
 	"github.com/werf/lockgate"
 
 	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/http"
 	"github.com/werf/multiwerf/pkg/locker"
-	"github.com/werf/multiwerf/pkg/repo"
 )
 
 func UpdateChannelVersionBinary(messages chan ActionMessage, group string, channel string, tryRemoteChannelMapping bool) (binInfo *BinaryInfo) {
@@ -37,18 +36,34 @@ func UpdateChannelVersionBinary(messages chan ActionMessage, group string, chann
 		msgType: OkMsgType,
 	}
 
-	_ = lockgate.WithAcquire(locker.Locker, actualChannelVersion, lockgate.AcquireOptions{}, func(_ bool) error {
-		localBinaryInfo, err := verifiedLocalBinaryInfo(messages, actualChannelVersion)
+	return updateVersionBinary(messages, actualChannelVersion, fmt.Sprintf("%s/%s", group, channel), channelMapping)
+}
+
+// UpdateExactVersionBinary downloads the exact version if it is not available locally
+func UpdateExactVersionBinary(messages chan ActionMessage, version string) (binInfo *BinaryInfo) {
+	messages <- ActionMessage{
+		msg:   "Start UpdateExactVersionBinary",
+		debug: true,
+	}
+
+	return updateVersionBinary(messages, version, version, nil)
+}
+
+// updateVersionBinary verifies the local version and downloads it if needed.
+// The channel mapping is saved if it is passed and the version is available.
+func updateVersionBinary(messages chan ActionMessage, version, target string, channelMapping ChannelMapping) (binInfo *BinaryInfo) {
+	_ = lockgate.WithAcquire(locker.Locker, version, lockgate.AcquireOptions{}, func(_ bool) error {
+		localBinaryInfo, err := verifiedLocalBinaryInfo(messages, version)
 		if err != nil {
 			messages <- ActionMessage{
-				err: fmt.Errorf("the local version %s verification failed: %s", actualChannelVersion, err.Error()),
+				err: fmt.Errorf("the local version %s verification failed: %s", version, err.Error()),
 			}
 
 			return nil
 		} else if localBinaryInfo != nil {
 			if !localBinaryInfo.HashVerified {
 				messages <- ActionMessage{
-					msg:     fmt.Sprintf("The local version %s has invalid or corrupted files and will be overrided", actualChannelVersion),
+					msg:     fmt.Sprintf("The local version %s has invalid or corrupted files and will be overrided", version),
 					msgType: WarnMsgType,
 				}
 
@@ -65,8 +80,8 @@ func UpdateChannelVersionBinary(messages chan ActionMessage, group string, chann
 					msgType: OkMsgType,
 				}
 
-				if err := channelMapping.Save(); err != nil {
-					messages <- ActionMessage{err: fmt.Errorf("save channel mapping failed: %s", err)}
+				if err := saveChannelMapping(channelMapping); err != nil {
+					messages <- ActionMessage{err: err}
 					return nil
 				}
 
@@ -75,14 +90,14 @@ func UpdateChannelVersionBinary(messages chan ActionMessage, group string, chann
 			}
 		}
 
-		downloadedBinaryInfo, err := downloadAndVerifyReleaseFiles(messages, actualChannelVersion)
+		downloadedBinaryInfo, err := downloadAndVerifyReleaseFiles(messages, version)
 		if err != nil {
-			messages <- ActionMessage{err: fmt.Errorf("%s %s/%s: %v", app.AppPackageName, group, channel, err)}
+			messages <- ActionMessage{err: fmt.Errorf("%s %s: %v", app.AppPackageName, target, err)}
 			return nil
 		}
 
-		if err := channelMapping.Save(); err != nil {
-			messages <- ActionMessage{err: fmt.Errorf("save channel mapping failed: %s", err)}
+		if err := saveChannelMapping(channelMapping); err != nil {
+			messages <- ActionMessage{err: err}
 			return nil
 		}
 
@@ -99,6 +114,18 @@ func UpdateChannelVersionBinary(messages chan ActionMessage, group string, chann
 	return binInfo
 }
 
+func saveChannelMapping(channelMapping ChannelMapping) error {
+	if channelMapping == nil {
+		return nil
+	}
+
+	if err := channelMapping.Save(); err != nil {
+		return fmt.Errorf("save channel mapping failed: %s", err)
+	}
+
+	return nil
+}
+
 func UseChannelVersionBinary(messages chan ActionMessage, group string, channel string) (binInfo *BinaryInfo) {
 	messages <- ActionMessage{
 		msg:   "Starting UseChannelVersionBinary",
@@ -139,7 +166,7 @@ func UseChannelVersionBinary(messages chan ActionMessage, group string, channel
 		msgType: OkMsgType,
 	}
 
-	localBinaryInfo, err := localBinaryInfo(messages, actualChannelVersion)
+	localBinaryInfo, err := lockedLocalBinaryInfo(messages, actualChannelVersion)
 	if err != nil {
 		messages <- ActionMessage{err: fmt.Errorf("the local version %s getting failed: %s", actualChannelVersion, err.Error())}
 		return nil
@@ -159,11 +186,191 @@ func UseChannelVersionBinary(messages chan ActionMessage, group string, channel
 	return nil
 }
 
+// UseExactVersionBinary returns the exact version binary if it is available locally
+func UseExactVersionBinary(messages chan ActionMessage, version string) (binInfo *BinaryInfo) {
+	messages <- ActionMessage{
+		msg:   "Starting UseExactVersionBinary",
+		debug: true,
+	}
+
+	if binaryPath := os.Getenv("MULTIWERF_WERF_PATH_FORCE"); binaryPath != "" {
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("Force binary path %s is used", binaryPath),
+			debug: true,
+		}
+
+		return &BinaryInfo{
+			BinaryPath: binaryPath,
+		}
+	}
+
+	localBinaryInfo, err := lockedLocalBinaryInfo(messages, version)
+	if err != nil {
+		messages <- ActionMessage{err: fmt.Errorf("the local version %s getting failed: %s", version, err.Error())}
+		return nil
+	} else if localBinaryInfo != nil {
+		return localBinaryInfo
+	}
+
+	messages <- ActionMessage{
+		err: fmt.Errorf("the version %s has not been found locally\nRun command `multiwerf update %s`", version, version),
+	}
+
+	return nil
+}
+
+// UpdateConstraintVersionBinary downloads the highest version from the repository that matches the constraint
+// if it is not available locally
+func UpdateConstraintVersionBinary(messages chan ActionMessage, constraint string) (binInfo *BinaryInfo) {
+	messages <- ActionMessage{
+		msg:   "Start UpdateConstraintVersionBinary",
+		debug: true,
+	}
+
+	version, err := highestRepoVersionByConstraint(messages, constraint)
+	if err != nil {
+		messages <- ActionMessage{err: err}
+		return nil
+	}
+
+	messages <- ActionMessage{
+		msg:     fmt.Sprintf("The version %s is the actual for constraint %q", version, constraint),
+		msgType: OkMsgType,
+	}
+
+	return updateVersionBinary(messages, version, fmt.Sprintf("%q", constraint), nil)
+}
+
+// UseConstraintVersionBinary returns the highest local version that matches the constraint
+func UseConstraintVersionBinary(messages chan ActionMessage, constraint string) (binInfo *BinaryInfo) {
+	messages <- ActionMessage{
+		msg:   "Starting UseConstraintVersionBinary",
+		debug: true,
+	}
+
+	versions, err := availableVersions()
+	if err != nil {
+		messages <- ActionMessage{err: err}
+		return nil
+	}
+
+	version, err := HighestSemverVersionByConstraint(versions, constraint)
+	if err != nil {
+		messages <- ActionMessage{err: err}
+		return nil
+	} else if version == "" {
+		messages <- ActionMessage{
+			err: fmt.Errorf("the version matching constraint %q has not been found locally\nRun command `multiwerf update '%s'`", constraint, constraint),
+		}
+
+		return nil
+	}
+
+	messages <- ActionMessage{
+		msg:     fmt.Sprintf("The version %s is the actual for constraint %q", version, constraint),
+		msgType: OkMsgType,
+	}
+
+	return UseExactVersionBinary(messages, version)
+}
+
+// highestRepoVersionByConstraint returns the highest version that matches the constraint from the first available repository
+func highestRepoVersionByConstraint(messages chan ActionMessage, constraint string) (string, error) {
+	repoClients, err := appRepoClients()
+	if err != nil {
+		return "", err
+	}
+
+	for ind, repoClient := range repoClients {
+		shouldSkipError := len(repoClients) > ind+1
+
+		versions, err := repoClient.GetPackageVersions()
+		if err != nil {
+			if shouldSkipError {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("[%s] Getting versions failed: %s", repoClient.String(), err),
+					msgType: WarnMsgType,
+				}
+
+				continue
+			}
+
+			return "", fmt.Errorf("[%s] getting versions failed: %s", repoClient.String(), err)
+		}
+
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("[%s] Discover %d versions: %+v", repoClient.String(), len(versions), versions),
+			debug: true,
+		}
+
+		version, err := HighestSemverVersionByConstraint(versions, constraint)
+		if err != nil {
+			return "", err
+		} else if version == "" {
+			// the version can be published to the next repository only
+			if shouldSkipError {
+				messages <- ActionMessage{
+					msg:     fmt.Sprintf("[%s] No version matches constraint %q", repoClient.String(), constraint),
+					msgType: WarnMsgType,
+				}
+
+				continue
+			}
+
+			return "", fmt.Errorf("[%s] no version matches constraint %q", repoClient.String(), constraint)
+		}
+
+		return version, nil
+	}
+
+	return "", fmt.Errorf("no repositories configured")
+}
+
+func updateSelectedVersionBinary(messages chan ActionMessage, selector *VersionSelector, tryRemoteChannelMapping bool) *BinaryInfo {
+	var binInfo *BinaryInfo
+	switch {
+	case selector.IsExactVersion():
+		binInfo = UpdateExactVersionBinary(messages, selector.Version)
+	case selector.IsConstraint():
+		binInfo = UpdateConstraintVersionBinary(messages, selector.Constraint)
+	default:
+		return UpdateChannelVersionBinary(messages, selector.Group, selector.Channel, tryRemoteChannelMapping)
+	}
+
+	// the pinned version is not in the channel mapping and would be removed by GC
+	if binInfo != nil {
+		markVersionPinned(messages, binInfo.Version)
+	}
+
+	return binInfo
+}
+
+// useSelectedVersionBinary returns the local binary for the selector and records the usage of its version for GC
+func useSelectedVersionBinary(messages chan ActionMessage, selector *VersionSelector) *BinaryInfo {
+	var binInfo *BinaryInfo
+	switch {
+	case selector.IsExactVersion():
+		binInfo = UseExactVersionBinary(messages, selector.Version)
+	case selector.IsConstraint():
+		binInfo = UseConstraintVersionBinary(messages, selector.Constraint)
+	default:
+		binInfo = UseChannelVersionBinary(messages, selector.Group, selector.Channel)
+	}
+
+	// the forced binary path has no version
+	if binInfo != nil && binInfo.Version != "" {
+		markVersionUsed(messages, binInfo.Version, !selector.IsChannel())
+	}
+
+	return binInfo
+}
+
 // downloadAndVerifyReleaseFiles downloads release files and verifies them.
-// If files are good then creates version directory and moves files there
+// If files are good then creates version directory and moves files there.
+// Files are downloaded into the same tmp dir of the version, so the interrupted download is resumed by the next update.
 func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string) (binInfo *BinaryInfo, err error) {
-	tmpDir, err := ioutil.TempDir(TmpDir, version+"-")
-	if err != nil {
+	tmpDir := versionDownloadDirPath(version)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
 		return nil, fmt.Errorf("create tmp dir failed: %s", err)
 	}
 
@@ -177,11 +384,12 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 		}
 	}()
 
-	repoClients := []repo.Repo{
-		NewAppS3Client(),
-		NewAppBtClient(),
+	repoClients, err := appRepoClients()
+	if err != nil {
+		return nil, err
 	}
 
+	var downloadedHashes map[string]string
 	for ind, repoClient := range repoClients {
 		messages <- ActionMessage{
 			msg:     fmt.Sprintf("[%s] Downloading the version %s ...", repoClient.String(), version),
@@ -190,7 +398,7 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 
 		shouldSkipError := len(repoClients) > ind+1
 
-		err = repoClient.DownloadFiles(version, tmpDir, files)
+		downloadedHashes, err = repoClient.DownloadFiles(version, tmpDir, RequiredReleaseFiles(files), downloadOptions(messages))
 		if err != nil {
 			if shouldSkipError {
 				messages <- ActionMessage{
@@ -202,9 +410,28 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 				continue
 			}
 
+			// partial files are kept to resume the download
+			shouldBeRemoved = false
+
 			return nil, err
 		}
 
+		// the signature file is optional and its absence is handled by verifyReleaseSignature,
+		// other errors fail the update, so the unavailable signature is not taken for the unsigned release
+		if _, err := repoClient.DownloadFiles(version, tmpDir, map[string]string{"sig": files["sig"]}, downloadOptions(nil)); err != nil {
+			if !http.IsNotFoundError(err) {
+				// downloaded files are kept to resume the download
+				shouldBeRemoved = false
+
+				return nil, fmt.Errorf("[%s] downloading the signature of the version %s failed: %s", repoClient.String(), version, err)
+			}
+
+			messages <- ActionMessage{
+				msg:   fmt.Sprintf("[%s] The signature of the version %s is not found: %s", repoClient.String(), version, err.Error()),
+				debug: true,
+			}
+		}
+
 		break
 	}
 
@@ -213,7 +440,8 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 	}
 
 	// check hash of local binary
-	match, err := VerifyReleaseFileHash(messages, tmpDir, files["hash"], files["program"])
+	// the hash of the binary is calculated while downloading
+	match, err := VerifyDownloadedReleaseFileHash(messages, tmpDir, files["hash"], files["program"], downloadedHashes[files["program"]])
 	if err != nil {
 		messages <- ActionMessage{
 			msg:   fmt.Sprintf("verifying release %s error: %v", version, err),
@@ -224,6 +452,13 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 	}
 
 	if match {
+		verified, err := verifyReleaseSignature(messages, tmpDir, files["hash"], files["sig"])
+		if err != nil {
+			return nil, err
+		} else if !verified {
+			return nil, fmt.Errorf("the release %s signature verification failed", version)
+		}
+
 		if err = os.Rename(tmpDir, dstPath); err != nil {
 			return nil, err
 		}
@@ -238,5 +473,11 @@ func downloadAndVerifyReleaseFiles(messages chan ActionMessage, version string)
 		return binInfo, nil
 	}
 
-	return
+	return nil, fmt.Errorf("the release %s hash verification failed: the checksum of %s does not match %s", version, files["program"], files["hash"])
+}
+
+// versionDownloadDirPath returns the tmp dir to download release files of the version.
+// It is used under the version lock and is removed by GC as other interrupted downloads <version>-*.
+func versionDownloadDirPath(version string) string {
+	return filepath.Join(TmpDir, version+"-download")
 }
@@ -0,0 +1,142 @@
+package multiwerf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/locker"
+	"github.com/werf/multiwerf/pkg/util"
+)
+
+const UsageIndexLockName = "usage"
+
+// usageIndex keeps the last time every local version has been resolved by werf-path, werf-exec or the use script
+type usageIndex struct {
+	Versions map[string]time.Time `json:"versions"`
+	// Pinned keeps the last time every local version has been selected by the exact version or the semver constraint
+	Pinned map[string]time.Time `json:"pinned,omitempty"`
+}
+
+func newUsageIndex() *usageIndex {
+	return &usageIndex{Versions: map[string]time.Time{}, Pinned: map[string]time.Time{}}
+}
+
+func usageIndexPath() string {
+	return filepath.Join(StorageDir, "usage.json")
+}
+
+// readUsageIndex returns the empty index if the usage index does not exist
+func readUsageIndex() (*usageIndex, error) {
+	index := newUsageIndex()
+
+	path := usageIndexPath()
+	if exist, err := FileExists(path); err != nil {
+		return nil, fmt.Errorf("file exists failed %s: %s", path, err)
+	} else if !exist {
+		return index, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file failed %s: %s", path, err)
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("unable to parse usage index %s: %s", path, err)
+	}
+
+	if index.Versions == nil {
+		index.Versions = map[string]time.Time{}
+	}
+
+	if index.Pinned == nil {
+		index.Pinned = map[string]time.Time{}
+	}
+
+	return index, nil
+}
+
+func writeUsageIndex(index *usageIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := util.CreateAtomicFile(usageIndexPath())
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Discard()
+		return fmt.Errorf("write file failed %s: %s", usageIndexPath(), err)
+	}
+
+	_, err = file.Commit()
+	return err
+}
+
+// updateUsageIndex modifies the usage index under the lock, the broken index is replaced with the empty one
+func updateUsageIndex(modify func(index *usageIndex)) error {
+	_, lockHandle, err := locker.Locker.Acquire(UsageIndexLockName, lockgate.AcquireOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to acquire a lock %s: %s", UsageIndexLockName, err)
+	}
+	defer func() { _ = locker.Locker.Release(lockHandle) }()
+
+	index, err := readUsageIndex()
+	if err != nil {
+		index = newUsageIndex()
+	}
+
+	modify(index)
+
+	return writeUsageIndex(index)
+}
+
+// markVersionUsed records that the binary of the version has been resolved and whether it has been pinned.
+// The error is not critical for the caller, the binary is still usable.
+func markVersionUsed(messages chan ActionMessage, version string, pinned bool) {
+	err := updateUsageIndex(func(index *usageIndex) {
+		now := time.Now().UTC()
+		index.Versions[version] = now
+		if pinned {
+			index.Pinned[version] = now
+		}
+	})
+	if err != nil {
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("Unable to update usage index: %s", err),
+			debug: true,
+		}
+	}
+}
+
+// markVersionPinned records that the version has been selected by the exact version or the semver constraint,
+// GC keeps pinned versions even if they are missing in the channel mapping
+func markVersionPinned(messages chan ActionMessage, version string) {
+	err := updateUsageIndex(func(index *usageIndex) {
+		index.Pinned[version] = time.Now().UTC()
+	})
+	if err != nil {
+		messages <- ActionMessage{
+			msg:   fmt.Sprintf("Unable to update usage index: %s", err),
+			debug: true,
+		}
+	}
+}
+
+// forgetVersionsUsage removes removed versions from the usage index
+func forgetVersionsUsage(versions []string) error {
+	return updateUsageIndex(func(index *usageIndex) {
+		for _, version := range versions {
+			delete(index.Versions, version)
+			delete(index.Pinned, version)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+func Test_usageIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-usage-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir string) { StorageDir = storageDir }(StorageDir)
+	StorageDir = dir
+	assert.NoError(t, locker.Init(filepath.Join(dir, "locks")))
+
+	index, err := readUsageIndex()
+	assert.NoError(t, err)
+	assert.Empty(t, index.Versions, "the usage index does not exist")
+
+	messages := make(chan ActionMessage, 10)
+	markVersionUsed(messages, "v1.1.0", false)
+	markVersionUsed(messages, "v1.2.0", true)
+	assert.Empty(t, messages)
+
+	index, err = readUsageIndex()
+	assert.NoError(t, err)
+	assert.Len(t, index.Versions, 2)
+	assert.False(t, index.Versions["v1.1.0"].IsZero())
+
+	markVersionPinned(messages, "v1.1.0")
+	assert.Empty(t, messages)
+
+	index, err = readUsageIndex()
+	assert.NoError(t, err)
+	assert.False(t, index.Pinned["v1.1.0"].IsZero())
+
+	assert.NoError(t, forgetVersionsUsage([]string{"v1.1.0"}))
+
+	index, err = readUsageIndex()
+	assert.NoError(t, err)
+	assert.Len(t, index.Versions, 1)
+	assert.Contains(t, index.Versions, "v1.2.0")
+	assert.NotContains(t, index.Pinned, "v1.1.0")
+	assert.Contains(t, index.Pinned, "v1.2.0")
+
+	assert.NoError(t, ioutil.WriteFile(usageIndexPath(), []byte("broken"), 0644))
+	_, err = readUsageIndex()
+	assert.Error(t, err)
+
+	markVersionUsed(messages, "v1.3.0", false)
+	index, err = readUsageIndex()
+	assert.NoError(t, err, "the broken usage index should be replaced")
+	assert.Len(t, index.Versions, 1)
+}
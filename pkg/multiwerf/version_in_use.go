@@ -0,0 +1,77 @@
+package multiwerf
+
+import (
+	"fmt"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/multiwerf/pkg/locker"
+	"github.com/werf/multiwerf/pkg/util"
+)
+
+// versionInUseLockName is held shared while the version binary is resolved or running and exclusively by GC while the version is removed.
+// The lock differs from the version lock to not block the update of the running version.
+func versionInUseLockName(version string) string {
+	return fmt.Sprintf("%s-in-use", version)
+}
+
+// acquireVersionInUseLock waits for GC if it is removing the version
+func acquireVersionInUseLock(version string) (*lockgate.LockHandle, error) {
+	_, lockHandle, err := locker.Locker.Acquire(versionInUseLockName(version), lockgate.AcquireOptions{Shared: true})
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock for version %s failed: %s", version, err)
+	}
+
+	return &lockHandle, nil
+}
+
+// lockedLocalBinaryInfo returns BinaryInfo holding the in-use lock of the version, the lock should be released with ReleaseInUseLock.
+// Nil is returned if no binary found.
+func lockedLocalBinaryInfo(messages chan ActionMessage, version string) (*BinaryInfo, error) {
+	lockHandle, err := acquireVersionInUseLock(version)
+	if err != nil {
+		return nil, err
+	}
+
+	binInfo, err := localBinaryInfo(messages, version)
+	if err != nil || binInfo == nil {
+		_ = locker.Locker.Release(*lockHandle)
+		return nil, err
+	}
+
+	binInfo.inUseLockHandle = lockHandle
+
+	return binInfo, nil
+}
+
+// ReleaseInUseLock allows GC to remove the version
+func (b *BinaryInfo) ReleaseInUseLock() {
+	if b == nil || b.inUseLockHandle == nil {
+		return
+	}
+
+	_ = locker.Locker.Release(*b.inUseLockHandle)
+	b.inUseLockHandle = nil
+}
+
+// withVersionNotInUse calls f holding the exclusive in-use lock of the version.
+// The reason is returned and f is not called if the version is used by werf-exec, werf-path or a running process.
+func withVersionNotInUse(version string, f func() error) (string, error) {
+	isAcquired, lockHandle, err := locker.Locker.Acquire(versionInUseLockName(version), lockgate.AcquireOptions{NonBlocking: true})
+	if err != nil {
+		return "", fmt.Errorf("acquire lock for version %s failed: %s", version, err)
+	} else if !isAcquired {
+		return "it is used by another multiwerf process", nil
+	}
+	defer func() { _ = locker.Locker.Release(lockHandle) }()
+
+	// the binary can be run by the path printed by werf-path or by the use script
+	pids, err := util.ProcessesWithExeInDir(localVersionDirPath(version))
+	if err != nil {
+		return "", fmt.Errorf("check processes of version %s failed: %s", version, err)
+	} else if len(pids) != 0 {
+		return fmt.Sprintf("it is used by running processes %v", pids), nil
+	}
+
+	return "", f()
+}
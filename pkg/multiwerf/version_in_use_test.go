@@ -0,0 +1,74 @@
+package multiwerf
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/werf/multiwerf/pkg/app"
+	"github.com/werf/multiwerf/pkg/locker"
+)
+
+func Test_withVersionNotInUse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multiwerf-in-use-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(storageDir string) { StorageDir = storageDir }(StorageDir)
+	StorageDir = dir
+	assert.NoError(t, locker.Init(filepath.Join(dir, "locks")))
+
+	const version = "v1.1.0"
+	programPath := filepath.Join(localVersionDirPath(version), ReleaseFiles(app.AppPackageName, version, app.OsArch)["program"])
+	assert.NoError(t, os.MkdirAll(filepath.Dir(programPath), 0755))
+	assert.NoError(t, ioutil.WriteFile(programPath, nil, 0755))
+
+	messages := make(chan ActionMessage, 10)
+	binInfo, err := lockedLocalBinaryInfo(messages, version)
+	assert.NoError(t, err)
+	assert.NotNil(t, binInfo)
+
+	called := false
+	reason, err := withVersionNotInUse(version, func() error { called = true; return nil })
+	assert.NoError(t, err)
+	assert.NotEmpty(t, reason, "the version is locked by werf-exec")
+	assert.False(t, called)
+
+	binInfo.ReleaseInUseLock()
+
+	reason, err = withVersionNotInUse(version, func() error { called = true; return nil })
+	assert.NoError(t, err)
+	assert.Empty(t, reason)
+	assert.True(t, called)
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	data, err := ioutil.ReadFile(sleepPath)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(programPath, data, 0755))
+
+	cmd := exec.Command(programPath, "60")
+	assert.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	called = false
+	reason, err = withVersionNotInUse(version, func() error { called = true; return nil })
+	assert.NoError(t, err)
+	assert.Contains(t, reason, "running processes")
+	assert.False(t, called, "the binary is running by the path printed by werf-path")
+}
@@ -0,0 +1,195 @@
+package multiwerf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/werf/multiwerf/pkg/output"
+)
+
+const DefaultChannel = "stable"
+
+// VersionSelector selects the werf version by the group and the channel of the channel mapping,
+// by the exact version or by the semver constraint
+type VersionSelector struct {
+	Group   string
+	Channel string
+	// Version is the exact version
+	Version string
+	// Constraint is resolved to the highest matching version, e.g. ~1.2.30 or >=1.2.10 <1.3
+	Constraint string
+}
+
+// NewVersionSelector returns the selector of the group and the channel if groupOrVersion is in form MAJOR.MINOR,
+// the selector of the exact version if it is in form vMAJOR.MINOR.PATCH and the selector of the semver constraint otherwise
+func NewVersionSelector(groupOrVersion, channel string) (*VersionSelector, error) {
+	if CheckMajorMinor(groupOrVersion) == nil {
+		if channel == "" {
+			channel = DefaultChannel
+		}
+
+		channel = NormalizeChannel(channel)
+		if channelIndex(channel) == len(Channels) {
+			return nil, fmt.Errorf("the channel %q is not valid: expected one of %s", channel, strings.Join(Channels, "|"))
+		}
+
+		return &VersionSelector{Group: groupOrVersion, Channel: channel}, nil
+	}
+
+	var selector *VersionSelector
+	switch {
+	case IsExactVersion(groupOrVersion):
+		selector = &VersionSelector{Version: normalizeExactVersion(groupOrVersion)}
+	case IsVersionConstraint(groupOrVersion):
+		selector = &VersionSelector{Constraint: groupOrVersion}
+	default:
+		return nil, fmt.Errorf("%q should be the group in form MAJOR.MINOR, the exact version in form vMAJOR.MINOR.PATCH or the semver constraint", groupOrVersion)
+	}
+
+	if channel != "" {
+		return nil, fmt.Errorf("the channel %s cannot be used with %s", channel, selector)
+	}
+
+	return selector, nil
+}
+
+// IsVersionSelector returns true if the argument is in form MAJOR.MINOR, vMAJOR.MINOR.PATCH or it is the semver constraint
+func IsVersionSelector(arg string) bool {
+	return CheckMajorMinor(arg) == nil || IsExactVersion(arg) || IsVersionConstraint(arg)
+}
+
+// IsVersionConstraint returns true if the argument is the semver constraint with an operator or a wildcard, e.g. ~1.2.30, 1.2.x or >=1.2.10 <1.3.
+// Bare wildcards such as x or * are not constraints, so werf args are not taken for the version selector.
+func IsVersionConstraint(arg string) bool {
+	if !strings.ContainsAny(arg, "~^<>=!*xX") || !strings.ContainsAny(arg, "0123456789") {
+		return false
+	}
+
+	_, err := newSemverConstraint(arg)
+	return err == nil
+}
+
+// IsExactVersion returns true if the version is in form [v]MAJOR.MINOR.PATCH[-PRERELEASE][+METADATA]
+func IsExactVersion(version string) bool {
+	if _, err := semver.NewVersion(version); err != nil {
+		return false
+	}
+
+	// semver also accepts MAJOR and MAJOR.MINOR
+	core := strings.SplitN(strings.SplitN(strings.TrimPrefix(version, "v"), "-", 2)[0], "+", 2)[0]
+	return strings.Count(core, ".") == 2
+}
+
+// normalizeExactVersion adds the v prefix that local version directories and release files are named with
+func normalizeExactVersion(version string) string {
+	if strings.HasPrefix(version, "v") {
+		return version
+	}
+
+	return "v" + version
+}
+
+// NormalizeChannel replaces legacy channel names
+func NormalizeChannel(channel string) string {
+	switch channel {
+	case "rc", "early-access":
+		return "ea"
+	default:
+		return channel
+	}
+}
+
+// IsChannel returns true if the version is selected by the channel mapping
+func (s *VersionSelector) IsChannel() bool {
+	return s.Group != ""
+}
+
+func (s *VersionSelector) IsExactVersion() bool {
+	return s.Version != ""
+}
+
+func (s *VersionSelector) IsConstraint() bool {
+	return s.Constraint != ""
+}
+
+// Args returns multiwerf command arguments to select the same version
+func (s *VersionSelector) Args() []string {
+	switch {
+	case s.IsExactVersion():
+		return []string{s.Version}
+	case s.IsConstraint():
+		return []string{s.Constraint}
+	default:
+		return []string{s.Group, s.Channel}
+	}
+}
+
+func (s *VersionSelector) String() string {
+	switch {
+	case s.IsExactVersion():
+		return s.Version
+	case s.IsConstraint():
+		return fmt.Sprintf("%q", s.Constraint)
+	default:
+		return fmt.Sprintf("%s/%s", s.Group, s.Channel)
+	}
+}
+
+// resolveVersionSelector returns the selector by arguments or by the project config if groupOrVersion is empty
+func resolveVersionSelector(groupOrVersion, channel string, printer output.Printer) (*VersionSelector, error) {
+	var selector *VersionSelector
+	messages := make(chan ActionMessage, 0)
+
+	go func() {
+		var err error
+		selector, err = versionSelector(messages, groupOrVersion, channel)
+		if err != nil {
+			messages <- ActionMessage{err: err}
+		}
+
+		messages <- ActionMessage{action: "exit"}
+	}()
+
+	if err := PrintActionMessages(messages, printer); err != nil {
+		return nil, err
+	}
+
+	return selector, nil
+}
+
+func versionSelector(messages chan ActionMessage, groupOrVersion, channel string) (*VersionSelector, error) {
+	if groupOrVersion != "" {
+		return NewVersionSelector(groupOrVersion, channel)
+	}
+
+	if channel != "" {
+		return nil, fmt.Errorf("the channel %s cannot be used without MAJOR.MINOR", channel)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get working directory failed: %s", err)
+	}
+
+	configPath, config, err := FindProjectConfig(wd)
+	if err != nil {
+		return nil, err
+	} else if config == nil {
+		return nil, fmt.Errorf("MAJOR.MINOR is required: the project config %s is not found in %s or its parent directories", ProjectConfigFilename, wd)
+	}
+
+	selector, err := config.VersionSelector()
+	if err != nil {
+		return nil, fmt.Errorf("the project config %s is not valid: %s", configPath, err)
+	}
+
+	messages <- ActionMessage{
+		msg:   fmt.Sprintf("The version %s is selected by the project config %s", selector, configPath),
+		debug: true,
+	}
+
+	return selector, nil
+}
@@ -0,0 +1,62 @@
+package multiwerf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewVersionSelector(t *testing.T) {
+	selector, err := NewVersionSelector("1.2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Group: "1.2", Channel: "stable"}, selector)
+	assert.Equal(t, []string{"1.2", "stable"}, selector.Args())
+
+	selector, err = NewVersionSelector("1.2", "rc")
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Group: "1.2", Channel: "ea"}, selector)
+
+	selector, err = NewVersionSelector("1.2.37", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Version: "v1.2.37"}, selector)
+	assert.Equal(t, []string{"v1.2.37"}, selector.Args())
+
+	selector, err = NewVersionSelector("v1.2.37-alpha.1+build.2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Version: "v1.2.37-alpha.1+build.2"}, selector)
+
+	selector, err = NewVersionSelector(">=1.2.10 <1.3", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &VersionSelector{Constraint: ">=1.2.10 <1.3"}, selector)
+	assert.Equal(t, []string{">=1.2.10 <1.3"}, selector.Args())
+
+	_, err = NewVersionSelector("~1.2.30", "stable")
+	assert.Error(t, err)
+
+	_, err = NewVersionSelector("v1.2.37", "stable")
+	assert.Error(t, err)
+
+	_, err = NewVersionSelector("1.2", "unknown")
+	assert.Error(t, err)
+
+	_, err = NewVersionSelector("1", "")
+	assert.Error(t, err)
+}
+
+func Test_IsVersionSelector(t *testing.T) {
+	for _, arg := range []string{"1.2", "v1.2.37", "1.2.37", "v1.2.37-beta.1", "~1.2.30", "1.2.x", ">=1.2.10 <1.3", ">=1.2.10, <1.3"} {
+		assert.True(t, IsVersionSelector(arg), arg)
+	}
+
+	for _, arg := range []string{"", "build", "1", "v1.2", "--env", "export", "~build", "x", "X", "*", ">=x", "x.x"} {
+		assert.False(t, IsVersionSelector(arg), arg)
+	}
+}
+
+func Test_quoteShellArgs(t *testing.T) {
+	args := []string{"~1.2.30", "it's", "--output-file=C:\\Users\\werf\\update.log"}
+
+	assert.Equal(t, `'~1.2.30' 'it'\''s' --output-file=C:\Users\werf\update.log`, quoteShellArgs("default", args))
+	assert.Equal(t, `'~1.2.30' 'it''s' --output-file=C:\Users\werf\update.log`, quoteShellArgs("powershell", args))
+	assert.Equal(t, `"~1.2.30" "it's" --output-file=C:\Users\werf\update.log`, quoteShellArgs("cmdexe", args))
+}
@@ -0,0 +1,79 @@
+package pgp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// ReadKeyRing parses armored or binary OpenPGP public keys
+func ReadKeyRing(data []byte) (openpgp.EntityList, error) {
+	if isArmored(data) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// ReadKeyRingFile parses armored or binary OpenPGP public keys from the file
+func ReadKeyRingFile(path string) (openpgp.EntityList, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keyring file %q: %s", path, err)
+	}
+
+	keyring, err := ReadKeyRing(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse keyring file %q: %s", path, err)
+	}
+
+	return keyring, nil
+}
+
+// CheckDetachedSignature verifies armored or binary detached signature of signed content
+// and returns the signer entity from keyring
+func CheckDetachedSignature(keyring openpgp.KeyRing, signed io.Reader, signature []byte) (*openpgp.Entity, error) {
+	if isArmored(signature) {
+		return openpgp.CheckArmoredDetachedSignature(keyring, signed, bytes.NewReader(signature))
+	}
+
+	return openpgp.CheckDetachedSignature(keyring, signed, bytes.NewReader(signature))
+}
+
+// CheckDetachedSignatureFile verifies the signature file sigPath of the file path
+func CheckDetachedSignatureFile(keyring openpgp.KeyRing, path, sigPath string) (*openpgp.Entity, error) {
+	fileReader, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %q: %s", path, err)
+	}
+	defer fileReader.Close()
+
+	sigData, err := ioutil.ReadFile(sigPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signature file %q: %s", sigPath, err)
+	}
+
+	return CheckDetachedSignature(keyring, fileReader, sigData)
+}
+
+// SignerNames returns identity names of the signer entity
+func SignerNames(signer *openpgp.Entity) []string {
+	var names []string
+	if signer == nil {
+		return names
+	}
+
+	for _, id := range signer.Identities {
+		names = append(names, id.Name)
+	}
+
+	return names
+}
+
+func isArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN"))
+}
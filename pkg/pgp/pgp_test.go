@@ -0,0 +1,48 @@
+package pgp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func Test_CheckDetachedSignature(t *testing.T) {
+	signer, err := openpgp.NewEntity("multiwerf", "test", "multiwerf@example.com", nil)
+	assert.NoError(t, err)
+
+	other, err := openpgp.NewEntity("other", "test", "other@example.com", nil)
+	assert.NoError(t, err)
+
+	var publicKey bytes.Buffer
+	armorWriter, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, signer.Serialize(armorWriter))
+	assert.NoError(t, armorWriter.Close())
+
+	keyring, err := ReadKeyRing(publicKey.Bytes())
+	assert.NoError(t, err)
+
+	content := "0000000000000000000000000000000000000000000000000000000000000000  werf\n"
+
+	var signature, armoredSignature, otherSignature bytes.Buffer
+	assert.NoError(t, openpgp.DetachSign(&signature, signer, strings.NewReader(content), nil))
+	assert.NoError(t, openpgp.ArmoredDetachSign(&armoredSignature, signer, strings.NewReader(content), nil))
+	assert.NoError(t, openpgp.DetachSign(&otherSignature, other, strings.NewReader(content), nil))
+
+	entity, err := CheckDetachedSignature(keyring, strings.NewReader(content), signature.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"multiwerf (test) <multiwerf@example.com>"}, SignerNames(entity))
+
+	_, err = CheckDetachedSignature(keyring, strings.NewReader(content), armoredSignature.Bytes())
+	assert.NoError(t, err)
+
+	_, err = CheckDetachedSignature(keyring, strings.NewReader(content+"tampered"), signature.Bytes())
+	assert.Error(t, err)
+
+	_, err = CheckDetachedSignature(keyring, strings.NewReader(content), otherSignature.Bytes())
+	assert.Error(t, err)
+}
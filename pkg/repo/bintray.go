@@ -3,10 +3,8 @@ package repo
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 
-	uuid "github.com/satori/go.uuid"
 	"github.com/werf/multiwerf/pkg/http"
 )
 
@@ -81,40 +79,25 @@ func GetPackageVersions(packageInfo string) (versions []string) {
 	return versions
 }
 
-func (bc *BintrayClient) DownloadFiles(version string, dstDir string, files map[string]string) error {
+func (bc *BintrayClient) DownloadFiles(version string, dstDir string, files map[string]string, options http.DownloadOptions) (map[string]string, error) {
 	if debug() {
 		fmt.Printf("-- BintrayClient.DownloadFiles version=%q dstDir=%q files=%#v\n", version, dstDir, files)
 	}
 
 	srcUrl := fmt.Sprintf("%s/%s/%s/%s", BintrayDlUrl, bc.Subject, bc.Repo, version)
 
+	hashes := map[string]string{}
 	for _, fileName := range files {
-		dstFilePath := filepath.Join(dstDir, fileName)
-		tmpFileName := fmt.Sprintf("%s.%s", fileName, uuid.NewV4().String())
-		tmpFilePath := filepath.Join(dstDir, tmpFileName)
-
 		fileUrl := fmt.Sprintf("%s/%s", srcUrl, fileName)
-
-		err := func() error {
-			defer os.RemoveAll(tmpFilePath)
-
-			if err := http.DownloadLargeFile(fileUrl, dstDir, fileName); err != nil {
-				return fmt.Errorf("%s download error: %v", fileUrl, err)
-			}
-
-			if err := os.Rename(tmpFilePath, dstFilePath); err != nil {
-				return fmt.Errorf("unable to rename %q to %q: %s", tmpFilePath, dstFilePath, err)
-			}
-
-			return nil
-		}()
-
+		hash, err := http.DownloadFile(fileUrl, filepath.Join(dstDir, fileName), fileDownloadOptions(options, fileName))
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("%s download error: %w", fileUrl, err)
 		}
+
+		hashes[fileName] = hash
 	}
 
-	return nil
+	return hashes, nil
 }
 
 func (bc *BintrayClient) GetFileContent(version string, fileName string) (string, error) {
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/werf/multiwerf/pkg/http"
+)
+
+const DefaultHTTPIndexUrlTemplate = "{base}/index.json"
+const DefaultHTTPFileUrlTemplate = "{base}/{version}/{file}"
+
+// HTTPClient is a generic repository that serves release files over plain HTTP(S).
+//
+// Versions are listed from an index document in the form {"versions": ["v1.2.3", ...]}.
+// Index and release file urls are built from templates with {base}, {version} and {file} placeholders.
+type HTTPClient struct {
+	BaseUrl          string
+	IndexUrlTemplate string
+	FileUrlTemplate  string
+}
+
+func NewHTTPClient(baseUrl string, indexUrlTemplate string, fileUrlTemplate string) (hc Repo) {
+	if indexUrlTemplate == "" {
+		indexUrlTemplate = DefaultHTTPIndexUrlTemplate
+	}
+	if fileUrlTemplate == "" {
+		fileUrlTemplate = DefaultHTTPFileUrlTemplate
+	}
+	hc = &HTTPClient{
+		BaseUrl:          strings.TrimRight(baseUrl, "/"),
+		IndexUrlTemplate: indexUrlTemplate,
+		FileUrlTemplate:  fileUrlTemplate,
+	}
+	return hc
+}
+
+func (hc *HTTPClient) GetPackageVersions() ([]string, error) {
+	if debug() {
+		fmt.Printf("-- HTTPClient.GetPackageVersions\n")
+	}
+
+	indexUrl := hc.indexUrl()
+	index, err := http.MakeRestAPICall("GET", indexUrl)
+	if err != nil {
+		return nil, fmt.Errorf("index %s GET error: %v", indexUrl, err)
+	}
+
+	return GetPackageVersions(index), nil
+}
+
+func (hc *HTTPClient) DownloadFiles(version string, dstDir string, files map[string]string, options http.DownloadOptions) (map[string]string, error) {
+	if debug() {
+		fmt.Printf("-- HTTPClient.DownloadFiles version=%q dstDir=%q files=%#v\n", version, dstDir, files)
+	}
+
+	hashes := map[string]string{}
+	for _, fileName := range files {
+		fileUrl := hc.fileUrl(version, fileName)
+		hash, err := http.DownloadFile(fileUrl, filepath.Join(dstDir, fileName), fileDownloadOptions(options, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("%s download error: %w", fileUrl, err)
+		}
+
+		hashes[fileName] = hash
+	}
+
+	return hashes, nil
+}
+
+func (hc *HTTPClient) GetFileContent(version string, fileName string) (string, error) {
+	if debug() {
+		fmt.Printf("-- HTTPClient.GetFileContent version=%q fileName=%q\n", version, fileName)
+	}
+
+	return http.MakeRestAPICall("GET", hc.fileUrl(version, fileName))
+}
+
+func (hc *HTTPClient) String() string {
+	return "http"
+}
+
+func (hc *HTTPClient) indexUrl() string {
+	return strings.NewReplacer("{base}", hc.BaseUrl).Replace(hc.IndexUrlTemplate)
+}
+
+func (hc *HTTPClient) fileUrl(version, fileName string) string {
+	return strings.NewReplacer(
+		"{base}", hc.BaseUrl,
+		"{version}", version,
+		"{file}", fileName,
+	).Replace(hc.FileUrlTemplate)
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	multiwerfHttp "github.com/werf/multiwerf/pkg/http"
+)
+
+// fakeHTTPRepoServer serves files by path and responds with the status for paths from statuses
+type fakeHTTPRepoServer struct {
+	*httptest.Server
+
+	files    map[string]string
+	statuses map[string]int
+
+	mux      sync.Mutex
+	requests []string
+}
+
+func newFakeHTTPRepoServer(files map[string]string) *fakeHTTPRepoServer {
+	s := &fakeHTTPRepoServer{files: files, statuses: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *fakeHTTPRepoServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.requests = append(s.requests, r.URL.Path)
+	s.mux.Unlock()
+
+	if status, ok := s.statuses[r.URL.Path]; ok {
+		w.WriteHeader(status)
+		return
+	}
+
+	content, ok := s.files[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	http.ServeContent(w, r, filepath.Base(r.URL.Path), time.Time{}, strings.NewReader(content))
+}
+
+func Test_HTTPClient(t *testing.T) {
+	server := newFakeHTTPRepoServer(map[string]string{
+		"/werf/index.json":         `{"versions": ["v1.2.3", "v1.2.4"]}`,
+		"/werf/v1.2.3/SHA256SUMS":  "sums",
+		"/werf/v1.2.3/werf-v1.2.3": "binary",
+	})
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL+"/werf/", "", "")
+
+	versions, err := client.GetPackageVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1.2.3", "v1.2.4"}, versions)
+
+	content, err := client.GetFileContent("v1.2.3", "SHA256SUMS")
+	assert.NoError(t, err)
+	assert.Equal(t, "sums", content)
+
+	dstDir, err := ioutil.TempDir("", "multiwerf-http-repo-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+
+	var events []multiwerfHttp.ProgressEvent
+	options := multiwerfHttp.DownloadOptions{
+		Retries: 1,
+		OnProgress: func(event multiwerfHttp.ProgressEvent) {
+			events = append(events, event)
+		},
+	}
+
+	hashes, err := client.DownloadFiles("v1.2.3", dstDir, map[string]string{"program": "werf-v1.2.3"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"werf-v1.2.3": fmt.Sprintf("%x", sha256.Sum256([]byte("binary")))}, hashes)
+
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, multiwerfHttp.ProgressEvent{Name: "werf-v1.2.3", Written: 6, Total: 6}, events[len(events)-1])
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dstDir, "werf-v1.2.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+}
+
+func Test_HTTPClient_UrlTemplates(t *testing.T) {
+	server := newFakeHTTPRepoServer(map[string]string{
+		"/mirror/werf.json":              `{"versions": ["v1.2.3"]}`,
+		"/mirror/files/werf-v1.2.3/sums": "sums",
+	})
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL+"/mirror", "{base}/werf.json", "{base}/files/werf-{version}/{file}")
+
+	versions, err := client.GetPackageVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1.2.3"}, versions)
+
+	content, err := client.GetFileContent("v1.2.3", "sums")
+	assert.NoError(t, err)
+	assert.Equal(t, "sums", content)
+}
+
+func Test_HTTPClient_Errors(t *testing.T) {
+	server := newFakeHTTPRepoServer(map[string]string{
+		"/werf/v1.2.3/werf-v1.2.3": "binary",
+	})
+	defer server.Close()
+
+	client := NewHTTPClient(server.URL+"/werf", "", "")
+
+	_, err := client.GetPackageVersions()
+	if assert.Error(t, err, "the index is not found") {
+		assert.Contains(t, err.Error(), "404")
+	}
+
+	server.statuses["/werf/index.json"] = http.StatusInternalServerError
+	_, err = client.GetPackageVersions()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "500")
+	}
+
+	_, err = client.GetFileContent("v1.2.3", "SHA256SUMS")
+	assert.Error(t, err, "the file is not found")
+
+	dstDir, err := ioutil.TempDir("", "multiwerf-http-repo-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+
+	server.requests = nil
+	_, err = client.DownloadFiles("v1.2.3", dstDir, map[string]string{"program": "missing"}, multiwerfHttp.DownloadOptions{Retries: 5})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "404")
+		assert.True(t, multiwerfHttp.IsNotFoundError(err), "the missing file should be distinguished from other errors")
+	}
+	assert.Len(t, server.requests, 1, "client errors should not be retried")
+
+	server.requests = nil
+	server.statuses["/werf/v1.2.3/werf-v1.2.3"] = http.StatusServiceUnavailable
+	_, err = client.DownloadFiles("v1.2.3", dstDir, map[string]string{"program": "werf-v1.2.3"}, multiwerfHttp.DownloadOptions{
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "503")
+		assert.False(t, multiwerfHttp.IsNotFoundError(err))
+	}
+	assert.Len(t, server.requests, 3, "server errors should be retried")
+
+	files, err := ioutil.ReadDir(dstDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files, "empty partial files should be removed")
+}
@@ -1,10 +1,15 @@
 package repo
 
-import "os"
+import (
+	"os"
+
+	"github.com/werf/multiwerf/pkg/http"
+)
 
 type Repo interface {
 	GetPackageVersions() ([]string, error)
-	DownloadFiles(version string, dstDir string, files map[string]string) error
+	// DownloadFiles atomically downloads release files into dstDir and returns their SHA256 by file names
+	DownloadFiles(version string, dstDir string, files map[string]string, options http.DownloadOptions) (map[string]string, error)
 	GetFileContent(version string, fileName string) (string, error)
 	String() string
 }
@@ -12,3 +17,16 @@ type Repo interface {
 func debug() bool {
 	return os.Getenv("MULTIWERF_DEBUG_REPO") == "1"
 }
+
+// fileDownloadOptions returns options that report progress events with the release file name
+func fileDownloadOptions(options http.DownloadOptions, fileName string) http.DownloadOptions {
+	if options.OnProgress != nil {
+		onProgress := options.OnProgress
+		options.OnProgress = func(event http.ProgressEvent) {
+			event.Name = fileName
+			onProgress(event)
+		}
+	}
+
+	return options
+}
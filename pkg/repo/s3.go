@@ -1,8 +1,9 @@
 package repo
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"path"
 	"path/filepath"
 	"strings"
@@ -13,19 +14,70 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 
-	uuid "github.com/satori/go.uuid"
+	"github.com/werf/multiwerf/pkg/http"
+	"github.com/werf/multiwerf/pkg/util"
 )
 
 const DefaultS3Endpoint = "s3.yandexcloud.net"
 const DefaultS3Region = "ru-central1"
 const DefaultS3ReleasesFolder = "targets/releases"
 
+const (
+	S3AnonymousCredentials = "anonymous"
+	S3StaticCredentials    = "static"
+	S3EnvCredentials       = "env"
+	S3ProfileCredentials   = "profile"
+)
+
 type S3Client struct {
-	bucket string
+	bucket  string
+	options S3Options
+}
+
+type S3Options struct {
+	Endpoint       string
+	Region         string
+	ForcePathStyle bool
+	ReleasesFolder string
+
+	// Credentials is one of anonymous (default), static, env or profile
+	Credentials     string
+	AccessKeyID     string
+	SecretAccessKey string
+	Profile         string
 }
 
-func NewS3Client(bucket string) (c S3Client) {
-	return S3Client{bucket: bucket}
+func NewS3Client(bucket string, options S3Options) (c S3Client) {
+	if options.Endpoint == "" {
+		options.Endpoint = DefaultS3Endpoint
+	}
+	if options.Region == "" {
+		options.Region = DefaultS3Region
+	}
+	if options.ReleasesFolder == "" {
+		options.ReleasesFolder = DefaultS3ReleasesFolder
+	}
+	options.ReleasesFolder = strings.Trim(options.ReleasesFolder, "/")
+	if options.Credentials == "" {
+		options.Credentials = S3AnonymousCredentials
+	}
+
+	return S3Client{bucket: bucket, options: options}
+}
+
+// ValidateS3Credentials returns error if credentials type is unknown or static keys are missing
+func ValidateS3Credentials(options S3Options) error {
+	switch options.Credentials {
+	case "", S3AnonymousCredentials, S3EnvCredentials, S3ProfileCredentials:
+	case S3StaticCredentials:
+		if options.AccessKeyID == "" || options.SecretAccessKey == "" {
+			return fmt.Errorf("access key id and secret access key are required for %s credentials", S3StaticCredentials)
+		}
+	default:
+		return fmt.Errorf("unknown credentials %q, expected %s, %s, %s or %s", options.Credentials, S3AnonymousCredentials, S3StaticCredentials, S3EnvCredentials, S3ProfileCredentials)
+	}
+
+	return nil
 }
 
 func (c S3Client) GetPackageVersions() ([]string, error) {
@@ -33,88 +85,125 @@ func (c S3Client) GetPackageVersions() ([]string, error) {
 		fmt.Printf("-- S3Client.GetPackageVersions\n")
 	}
 
-	awsConfig := c.awsConfig()
-	sess := session.Must(session.NewSession(awsConfig))
-	svc := s3.New(sess, awsConfig)
+	awsConfig, err := c.awsConfig()
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket: aws.String(c.bucket),
-		Prefix: aws.String(fmt.Sprintf("%s/v", DefaultS3ReleasesFolder)),
-	})
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
-		return nil, fmt.Errorf("listing s3 bucket failed: %s", err)
+		return nil, fmt.Errorf("s3 session creation failed: %s", err)
 	}
+	svc := s3.New(sess, awsConfig)
 
-	var versions []string
-	for _, obj := range res.Contents {
-		// targets/releases/<semver>/*
-		p := *obj.Key
+	// <releases folder>/<semver>/ common prefixes are listed page by page
+	releasesFolderPrefix := c.options.ReleasesFolder + "/"
 
-		// trim trailing slash: targets/releases/<semver>/ => targets/releases/<semver>
-		p = strings.TrimRight(p, "/")
+	var versions []string
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(c.bucket),
+		Prefix:    aws.String(releasesFolderPrefix + "v"),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, commonPrefix := range page.CommonPrefixes {
+			// targets/releases/<semver>/ => <semver>
+			version := strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(commonPrefix.Prefix), releasesFolderPrefix), "/")
+			if version == "" {
+				continue
+			}
 
-		// skip release files
-		dir, version := path.Split(p)
-		if dir != "targets/releases/" {
-			continue
+			versions = append(versions, version)
 		}
 
-		versions = append(versions, version)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing s3 bucket failed: %s", err)
 	}
 
 	return versions, nil
 }
 
-func (c S3Client) DownloadFiles(version string, dstDir string, files map[string]string) error {
+func (c S3Client) DownloadFiles(version string, dstDir string, files map[string]string, options http.DownloadOptions) (map[string]string, error) {
 	if debug() {
 		fmt.Printf("-- S3Client.DownloadFiles version=%q dstDir=%q files=%#v\n", version, dstDir, files)
 	}
 
-	awsConfig := c.awsConfig()
-	sess := session.Must(session.NewSession(awsConfig))
-	downloader := s3manager.NewDownloader(sess)
+	awsConfig, err := c.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// objects are streamed with download timeouts, failed downloads are retried and resumed by http.Download
+	awsConfig.HTTPClient = http.NewDownloadClient(options)
+	awsConfig.MaxRetries = aws.Int(0)
 
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("s3 session creation failed: %s", err)
+	}
+	svc := s3.New(sess)
+
+	hashes := map[string]string{}
 	for _, fileName := range files {
-		dstFilePath := filepath.Join(dstDir, fileName)
-		tmpFilePath := fmt.Sprintf("%s.%s", dstFilePath, uuid.NewV4().String())
-		key := releaseFileKey(version, fileName)
-
-		err := func() error {
-			dstFile, err := os.Create(tmpFilePath)
-			if err != nil {
-				return fmt.Errorf("unable to open file %q, %v", tmpFilePath, err)
-			}
-			defer func() {
-				if err != nil {
-					_ = dstFile.Close()
-					_ = os.RemoveAll(tmpFilePath)
-				}
-			}()
-
-			_, err = downloader.Download(dstFile, &s3.GetObjectInput{
-				Bucket: aws.String(c.bucket),
-				Key:    aws.String(key),
-			})
-			if err != nil {
-				return fmt.Errorf("downloading file %q failed: %s", tmpFilePath, err)
-			}
+		key := c.releaseFileKey(version, fileName)
 
-			if err = dstFile.Close(); err != nil {
-				return fmt.Errorf("unable to close file %q: %s", tmpFilePath, err)
-			}
+		// the partial file of the interrupted download is resumed
+		file, err := util.OpenPartialFile(filepath.Join(dstDir, fileName))
+		if err != nil {
+			return nil, err
+		}
 
-			if err = os.Rename(tmpFilePath, dstFilePath); err != nil {
-				return fmt.Errorf("unable to rename %q to %q: %s", tmpFilePath, dstFilePath, err)
-			}
+		if err := http.Download(fileName, c.openObject(svc, key), file, fileDownloadOptions(options, fileName)); err != nil {
+			file.Suspend()
+			return nil, fmt.Errorf("downloading file %q failed: %w", key, err)
+		}
 
-			return nil
-		}()
+		hash, err := file.Commit()
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		hashes[fileName] = hash
 	}
 
-	return nil
+	return hashes, nil
+}
+
+// openObject returns http.OpenFunc that requests the rest of the object with the Range parameter
+func (c S3Client) openObject(svc *s3.S3, key string) http.OpenFunc {
+	return func(ctx context.Context, offset int64) (io.ReadCloser, int64, int64, error) {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(c.bucket),
+			Key:    aws.String(key),
+		}
+
+		if offset > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		output, err := svc.GetObjectWithContext(ctx, input)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		total := int64(-1)
+		if output.ContentLength != nil {
+			total = *output.ContentLength
+		}
+
+		if output.ContentRange == nil {
+			return output.Body, 0, total, nil
+		}
+
+		start, size, ok := http.ParseContentRange(*output.ContentRange)
+		if !ok {
+			output.Body.Close()
+			return nil, 0, 0, fmt.Errorf("unexpected content range %q", *output.ContentRange)
+		}
+
+		return output.Body, start, size, nil
+	}
 }
 
 func (c S3Client) GetFileContent(version string, fileName string) (string, error) {
@@ -122,14 +211,21 @@ func (c S3Client) GetFileContent(version string, fileName string) (string, error
 		fmt.Printf("-- S3Client.GetFileContent version=%q fileName=%q\n", version, fileName)
 	}
 
-	awsConfig := c.awsConfig()
-	sess := session.Must(session.NewSession(awsConfig))
+	awsConfig, err := c.awsConfig()
+	if err != nil {
+		return "", err
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return "", fmt.Errorf("s3 session creation failed: %s", err)
+	}
 	downloader := s3manager.NewDownloader(sess)
 
-	key := releaseFileKey(version, fileName)
+	key := c.releaseFileKey(version, fileName)
 
 	buff := &aws.WriteAtBuffer{}
-	_, err := downloader.Download(buff, &s3.GetObjectInput{
+	_, err = downloader.Download(buff, &s3.GetObjectInput{
 		Bucket: aws.String(c.bucket),
 		Key:    aws.String(key),
 	})
@@ -137,18 +233,41 @@ func (c S3Client) GetFileContent(version string, fileName string) (string, error
 	return string(buff.Bytes()), err
 }
 
-func (c S3Client) awsConfig() *aws.Config {
-	return &aws.Config{
-		Endpoint:    aws.String(DefaultS3Endpoint),
-		Region:      aws.String(DefaultS3Region),
-		Credentials: credentials.AnonymousCredentials,
+func (c S3Client) awsConfig() (*aws.Config, error) {
+	if err := ValidateS3Credentials(c.options); err != nil {
+		return nil, err
 	}
+
+	var creds *credentials.Credentials
+	switch c.options.Credentials {
+	case S3StaticCredentials:
+		creds = credentials.NewStaticCredentials(c.options.AccessKeyID, c.options.SecretAccessKey, "")
+	case S3EnvCredentials:
+		creds = credentials.NewEnvCredentials()
+	case S3ProfileCredentials:
+		creds = credentials.NewSharedCredentials("", c.options.Profile)
+	default:
+		creds = credentials.AnonymousCredentials
+	}
+
+	client, err := http.NewClient(http.DefaultClientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aws.Config{
+		Endpoint:         aws.String(c.options.Endpoint),
+		Region:           aws.String(c.options.Region),
+		S3ForcePathStyle: aws.Bool(c.options.ForcePathStyle),
+		Credentials:      creds,
+		HTTPClient:       client,
+	}, nil
 }
 
 func (c S3Client) String() string {
 	return "s3"
 }
 
-func releaseFileKey(version, fileName string) string {
-	return path.Join(DefaultS3ReleasesFolder, version, fileName)
+func (c S3Client) releaseFileKey(version, fileName string) string {
+	return path.Join(c.options.ReleasesFolder, version, fileName)
 }
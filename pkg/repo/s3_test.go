@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	multiwerfHttp "github.com/werf/multiwerf/pkg/http"
+)
+
+// fakeS3Server is a minimal path-style S3 API stand-in that supports GetObject and ListObjectsV2
+type fakeS3Server struct {
+	*httptest.Server
+
+	bucket   string
+	objects  map[string]string
+	pageSize int
+
+	mux            sync.Mutex
+	authorizations []string
+	listRequests   int
+}
+
+func newFakeS3Server(bucket string, objects map[string]string) *fakeS3Server {
+	s := &fakeS3Server{bucket: bucket, objects: objects}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *fakeS3Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	s.mux.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != s.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 || parts[1] == "" {
+		s.listObjectsV2(w, r)
+		return
+	}
+
+	content, ok := s.objects[parts[1]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+		return
+	}
+
+	http.ServeContent(w, r, parts[1], time.Time{}, strings.NewReader(content))
+}
+
+func (s *fakeS3Server) listObjectsV2(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.listRequests++
+	s.mux.Unlock()
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, _ = strconv.Atoi(value)
+	}
+	if s.pageSize != 0 && s.pageSize < maxKeys {
+		maxKeys = s.pageSize
+	}
+
+	// keys and common prefixes are paginated together in lexicographical order
+	entries := map[string]bool{}
+	for key := range s.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if delimiter != "" {
+			if ind := strings.Index(key[len(prefix):], delimiter); ind != -1 {
+				entries[key[:len(prefix)+ind+len(delimiter)]] = true
+				continue
+			}
+		}
+
+		entries[key] = false
+	}
+
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+
+	end := start + maxKeys
+	if end > len(names) {
+		end = len(names)
+	}
+
+	type object struct {
+		Key  string
+		Size int
+	}
+
+	type commonPrefix struct {
+		Prefix string
+	}
+
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		MaxKeys               int
+		KeyCount              int
+		IsTruncated           bool
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []object
+		CommonPrefixes        []commonPrefix
+	}{
+		Name:              s.bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           maxKeys,
+		KeyCount:          end - start,
+		IsTruncated:       end < len(names),
+		ContinuationToken: query.Get("continuation-token"),
+	}
+
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+
+	for _, name := range names[start:end] {
+		if entries[name] {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: name})
+		} else {
+			result.Contents = append(result.Contents, object{Key: name, Size: len(s.objects[name])})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeS3Server) s3Options() S3Options {
+	return S3Options{
+		Endpoint:        s.URL,
+		Region:          "us-east-1",
+		ForcePathStyle:  true,
+		ReleasesFolder:  "mirror/releases",
+		Credentials:     S3StaticCredentials,
+		AccessKeyID:     "access-key-id",
+		SecretAccessKey: "secret-access-key",
+	}
+}
+
+func Test_S3Client(t *testing.T) {
+	server := newFakeS3Server("werf", map[string]string{
+		"mirror/releases/v1.2.3/":           "",
+		"mirror/releases/v1.2.3/SHA256SUMS": "sums",
+		"mirror/releases/v1.2.3/werf":       "binary",
+		"mirror/releases/v1.2.4/":           "",
+		"mirror/other/v1.2.5/":              "",
+	})
+	defer server.Close()
+
+	client := NewS3Client("werf", server.s3Options())
+
+	versions, err := client.GetPackageVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1.2.3", "v1.2.4"}, versions)
+
+	content, err := client.GetFileContent("v1.2.3", "SHA256SUMS")
+	assert.NoError(t, err)
+	assert.Equal(t, "sums", content)
+
+	dstDir, err := ioutil.TempDir("", "multiwerf-s3-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dstDir)
+
+	var events []multiwerfHttp.ProgressEvent
+	options := multiwerfHttp.DownloadOptions{
+		Retries: 1,
+		OnProgress: func(event multiwerfHttp.ProgressEvent) {
+			events = append(events, event)
+		},
+	}
+
+	hashes, err := client.DownloadFiles("v1.2.3", dstDir, map[string]string{"program": "werf"}, options)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"werf": fmt.Sprintf("%x", sha256.Sum256([]byte("binary")))}, hashes)
+
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, multiwerfHttp.ProgressEvent{Name: "werf", Written: 6, Total: 6}, events[len(events)-1])
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dstDir, "werf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+
+	_, err = client.DownloadFiles("v1.2.3", dstDir, map[string]string{"program": "missing"}, multiwerfHttp.DownloadOptions{Retries: 5})
+	assert.Error(t, err)
+
+	files, err := ioutil.ReadDir(dstDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files should be removed")
+
+	assert.NotEmpty(t, server.authorizations)
+	for _, authorization := range server.authorizations {
+		assert.Contains(t, authorization, "Credential=access-key-id/")
+	}
+}
+
+func Test_S3Client_GetPackageVersions_Pagination(t *testing.T) {
+	objects := map[string]string{
+		"targets/releases/latest/werf": "binary",
+		"targets/releases/vinfo.txt":   "",
+	}
+
+	var expectedVersions []string
+	for i := 0; i < 1200; i++ {
+		version := fmt.Sprintf("v1.2.%d", i)
+		expectedVersions = append(expectedVersions, version)
+
+		// several release files per version without directory markers
+		objects[fmt.Sprintf("targets/releases/%s/SHA256SUMS", version)] = "sums"
+		objects[fmt.Sprintf("targets/releases/%s/werf", version)] = "binary"
+	}
+	sort.Strings(expectedVersions)
+
+	server := newFakeS3Server("werf", objects)
+	defer server.Close()
+
+	options := server.s3Options()
+	options.ReleasesFolder = ""
+	client := NewS3Client("werf", options)
+
+	versions, err := client.GetPackageVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedVersions, versions)
+	assert.Equal(t, 2, server.listRequests)
+
+	server.listRequests = 0
+	server.pageSize = 100
+
+	versions, err = client.GetPackageVersions()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedVersions, versions)
+	assert.Equal(t, 13, server.listRequests)
+}
+
+func Test_ValidateS3Credentials(t *testing.T) {
+	assert.NoError(t, ValidateS3Credentials(S3Options{}))
+	assert.NoError(t, ValidateS3Credentials(S3Options{Credentials: S3EnvCredentials}))
+	assert.Error(t, ValidateS3Credentials(S3Options{Credentials: S3StaticCredentials}))
+	assert.Error(t, ValidateS3Credentials(S3Options{Credentials: "vault"}))
+}
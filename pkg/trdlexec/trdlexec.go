@@ -13,7 +13,9 @@ import (
 	"strings"
 
 	uuid "github.com/satori/go.uuid"
-	"golang.org/x/crypto/openpgp"
+
+	multiwerfHttp "github.com/werf/multiwerf/pkg/http"
+	"github.com/werf/multiwerf/pkg/pgp"
 )
 
 const (
@@ -184,27 +186,21 @@ func isTrdlInstalled(logWriter io.Writer) (bool, error) {
 }
 
 func checkSignature(logWriter io.Writer, path, sig string) error {
-	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader([]byte(trdlPGPSigningKey)))
+	keyring, err := pgp.ReadKeyRing([]byte(trdlPGPSigningKey))
 	if err != nil {
 		return fmt.Errorf("unable to parse sig file %q key: %s", sig, err)
 	}
 
-	fileReader, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("unable to open file %q: %s", path, err)
-	}
-
-	sigData, err := os.ReadFile(sig)
+	signer, err := pgp.CheckDetachedSignatureFile(keyring, path, sig)
 	if err != nil {
-		return fmt.Errorf("unable to read signature file %q: %s", sig, err)
+		return err
 	}
 
-	signer, err := openpgp.CheckDetachedSignature(keyring, fileReader, bytes.NewReader(sigData))
-	for _, id := range signer.Identities {
-		fmt.Fprintf(logWriter, "Signed by %s\n", id.Name)
+	for _, name := range pgp.SignerNames(signer) {
+		fmt.Fprintf(logWriter, "Signed by %s\n", name)
 	}
 
-	return err
+	return nil
 }
 
 func downloadFile(url, destFile string) error {
@@ -214,7 +210,7 @@ func downloadFile(url, destFile string) error {
 	}
 	defer out.Close()
 
-	resp, err := http.Get(url)
+	resp, err := multiwerfHttp.NewDownloadClient(multiwerfHttp.DefaultDownloadOptions).Get(url)
 	if err != nil {
 		return fmt.Errorf("unable to issue http get for %q: %s", url, err)
 	}
@@ -371,7 +367,7 @@ func setFlag(name string) error {
 	flagPath := getFlagPath(name)
 
 	if err := os.MkdirAll(filepath.Dir(flagPath), os.ModePerm); err != nil {
-		return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(flagPath))
+		return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(flagPath), err)
 	}
 
 	if err := os.WriteFile(flagPath, []byte{}, os.ModePerm); err != nil {
@@ -0,0 +1,13 @@
+// +build !linux,!darwin,!windows
+
+package util
+
+import (
+	"os"
+	"time"
+)
+
+// AccessTime returns the modification time of the file, the last access time is not available on this platform
+func AccessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,137 @@
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// AtomicFile is written into a temporary file next to the destination path and
+// appears at the destination path only after Commit.
+// SHA256 of the content is calculated while writing.
+type AtomicFile struct {
+	path    string
+	tmpPath string
+	file    *os.File
+	hash    hash.Hash
+	offset  int64
+}
+
+// CreateAtomicFile creates a temporary file for the destination path
+func CreateAtomicFile(path string) (*AtomicFile, error) {
+	tmpPath := fmt.Sprintf("%s.%s", path, uuid.NewV4().String())
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file %q: %s", tmpPath, err)
+	}
+
+	return &AtomicFile{
+		path:    path,
+		tmpPath: tmpPath,
+		file:    file,
+		hash:    sha256.New(),
+	}, nil
+}
+
+// OpenPartialFile opens the partial file PartialFilePath(path) that is kept by Suspend to resume writing.
+// The written content is hashed again and new content is appended to it.
+func OpenPartialFile(path string) (*AtomicFile, error) {
+	tmpPath := PartialFilePath(path)
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file %q: %s", tmpPath, err)
+	}
+
+	f := &AtomicFile{
+		path:    path,
+		tmpPath: tmpPath,
+		file:    file,
+		hash:    sha256.New(),
+	}
+
+	offset, err := io.Copy(f.hash, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("unable to read file %q: %s", tmpPath, err)
+	}
+	f.offset = offset
+
+	return f, nil
+}
+
+// PartialFilePath returns the path of the partial file for the destination path
+func PartialFilePath(path string) string {
+	return path + ".partial"
+}
+
+func (f *AtomicFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.hash.Write(p[:n])
+	f.offset += int64(n)
+
+	return n, err
+}
+
+// Offset returns the size of the written content
+func (f *AtomicFile) Offset() int64 {
+	return f.offset
+}
+
+// Reset discards the written content
+func (f *AtomicFile) Reset() error {
+	if err := f.file.Truncate(0); err != nil {
+		return fmt.Errorf("unable to truncate file %q: %s", f.tmpPath, err)
+	}
+
+	if _, err := f.file.Seek(0, 0); err != nil {
+		return fmt.Errorf("unable to seek file %q: %s", f.tmpPath, err)
+	}
+
+	f.hash.Reset()
+	f.offset = 0
+
+	return nil
+}
+
+// Commit flushes the content to the disk, renames the temporary file to the destination path
+// and returns SHA256 of the content
+func (f *AtomicFile) Commit() (string, error) {
+	if err := f.file.Sync(); err != nil {
+		f.Discard()
+		return "", fmt.Errorf("unable to sync file %q: %s", f.tmpPath, err)
+	}
+
+	if err := f.file.Close(); err != nil {
+		f.Discard()
+		return "", fmt.Errorf("unable to close file %q: %s", f.tmpPath, err)
+	}
+
+	if err := os.Rename(f.tmpPath, f.path); err != nil {
+		f.Discard()
+		return "", fmt.Errorf("unable to rename %q to %q: %s", f.tmpPath, f.path, err)
+	}
+
+	return fmt.Sprintf("%x", f.hash.Sum(nil)), nil
+}
+
+// Discard removes the temporary file
+func (f *AtomicFile) Discard() {
+	_ = f.file.Close()
+	_ = os.Remove(f.tmpPath)
+}
+
+// Suspend closes the partial file keeping the written content to resume it with OpenPartialFile.
+// The file is removed if nothing has been written.
+func (f *AtomicFile) Suspend() {
+	if f.offset == 0 {
+		f.Discard()
+		return
+	}
+
+	_ = f.file.Sync()
+	_ = f.file.Close()
+}
@@ -0,0 +1,8 @@
+// +build !linux
+
+package util
+
+// ProcessesWithExeInDir is only implemented on linux with /proc, in-use locks protect running versions on other systems
+func ProcessesWithExeInDir(_ string) ([]int, error) {
+	return nil, nil
+}
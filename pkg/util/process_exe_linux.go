@@ -0,0 +1,50 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcessesWithExeInDir returns pids of processes whose executable is inside the dir.
+// Processes of other users that cannot be inspected are skipped.
+func ProcessesWithExeInDir(dir string) ([]int, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolvedDir, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolvedDir
+	}
+
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		exe, err := os.Readlink(filepath.Join("/proc", entry.Name(), "exe"))
+		if err != nil {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSuffix(exe, " (deleted)"), dir+string(filepath.Separator)) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
@@ -0,0 +1,41 @@
+// fake_werf is a werf stand-in for integration tests that is served by util_test.ReleaseServer for every version.
+// The version is taken from the release file name <package>-<os>-<arch>-<version>[.exe].
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	path, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to get executable path: %s\n", err)
+		os.Exit(1)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".exe")
+	version := name[strings.LastIndex(name, "-")+1:]
+
+	if len(os.Args) > 1 && os.Args[1] == "version" {
+		fmt.Println(version)
+		return
+	}
+
+	// sleep keeps the binary running, e.g. while GC is performed
+	if len(os.Args) > 2 && os.Args[1] == "sleep" {
+		duration, err := time.ParseDuration(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bad duration: %s\n", err)
+			os.Exit(1)
+		}
+
+		time.Sleep(duration)
+	}
+
+	// args are quoted to check empty ones
+	fmt.Printf("fake werf %s: %q\n", version, os.Args[1:])
+}
@@ -0,0 +1,237 @@
+package util_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	. "github.com/onsi/gomega"
+)
+
+// ReleaseServer is an in-process stand-in for the remote channel mapping and the release repository.
+//
+// Channel mappings are served from /channel-mapping/<name>.
+// Release files are served in the HTTP repository layout: /<package>/index.json and /<package>/<version>/<file>.
+// SHA256SUMS of every release and channel mappings are signed with the signing key of the server.
+// Files are served with ETag headers to support conditional requests.
+type ReleaseServer struct {
+	*httptest.Server
+
+	signer *openpgp.Entity
+
+	mux         sync.Mutex
+	files       map[string][]byte
+	statuses    map[string]int
+	versions    map[string][]string
+	notModified map[string]int
+}
+
+// NewSigningKey generates the private key to sign releases with, the same key can be shared between parallel test nodes
+func NewSigningKey() []byte {
+	signer, err := openpgp.NewEntity("multiwerf integration tests", "", "", nil)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	var key bytes.Buffer
+	Ω(signer.SerializePrivate(&key, nil)).Should(Succeed())
+
+	return key.Bytes()
+}
+
+// PinnedSigningKey returns the base64 encoded public key of the signing key to pin in the multiwerf binary
+func PinnedSigningKey(signingKey []byte) string {
+	var key bytes.Buffer
+	Ω(readSigningKey(signingKey).Serialize(&key)).Should(Succeed())
+
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+// DetachSign returns the detached signature of data made with the signing key
+func DetachSign(signingKey []byte, data []byte) []byte {
+	var signature bytes.Buffer
+	Ω(openpgp.DetachSign(&signature, readSigningKey(signingKey), bytes.NewReader(data), nil)).Should(Succeed())
+
+	return signature.Bytes()
+}
+
+func readSigningKey(signingKey []byte) *openpgp.Entity {
+	signer, err := openpgp.ReadEntity(packet.NewReader(bytes.NewReader(signingKey)))
+	Ω(err).ShouldNot(HaveOccurred())
+
+	return signer
+}
+
+// NewReleaseServer serves releases signed with the signing key generated by NewSigningKey
+func NewReleaseServer(signingKey []byte) *ReleaseServer {
+	signer := readSigningKey(signingKey)
+
+	s := &ReleaseServer{
+		signer:      signer,
+		files:       map[string][]byte{},
+		statuses:    map[string]int{},
+		versions:    map[string][]string{},
+		notModified: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+func (s *ReleaseServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	status := s.statuses[r.URL.Path]
+	data, ok := s.files[r.URL.Path]
+	etag := fmt.Sprintf("\"%x\"", sha256.Sum256(data))
+	if ok && r.Header.Get("If-None-Match") == etag {
+		s.notModified[r.URL.Path]++
+	}
+	s.mux.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// ServeContent responds 304 Not Modified to the matching If-None-Match header
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(data))
+}
+
+// SetChannelMapping serves the channel mapping data by ChannelMappingUrl(name) and its detached signature next to it
+func (s *ReleaseServer) SetChannelMapping(name string, data []byte) {
+	var signature bytes.Buffer
+	Ω(openpgp.DetachSign(&signature, s.signer, bytes.NewReader(data), nil)).Should(Succeed())
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.files[path.Join("/channel-mapping", name)] = data
+	s.files[path.Join("/channel-mapping", name+".sig")] = signature.Bytes()
+}
+
+// SetUnsignedChannelMapping serves the channel mapping data by ChannelMappingUrl(name) without the signature
+func (s *ReleaseServer) SetUnsignedChannelMapping(name string, data []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.files[path.Join("/channel-mapping", name)] = data
+	delete(s.files, path.Join("/channel-mapping", name+".sig"))
+}
+
+func (s *ReleaseServer) ChannelMappingUrl(name string) string {
+	return fmt.Sprintf("%s/channel-mapping/%s", s.URL, name)
+}
+
+// ChannelMappingNotModifiedCount returns the number of 304 Not Modified responses to requests of ChannelMappingUrl(name)
+func (s *ReleaseServer) ChannelMappingNotModifiedCount(name string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.notModified[path.Join("/channel-mapping", name)]
+}
+
+// AddRelease serves release files of the package version with signed SHA256SUMS and adds the version to the package index
+func (s *ReleaseServer) AddRelease(pkg, version string, files map[string][]byte) {
+	var fileNames []string
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	var sha256sums bytes.Buffer
+	for _, fileName := range fileNames {
+		_, _ = fmt.Fprintf(&sha256sums, "%x  %s\n", sha256.Sum256(files[fileName]), fileName)
+	}
+
+	var signature bytes.Buffer
+	Ω(openpgp.DetachSign(&signature, s.signer, bytes.NewReader(sha256sums.Bytes()), nil)).Should(Succeed())
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for fileName, data := range files {
+		s.files[path.Join("/", pkg, version, fileName)] = data
+	}
+	s.files[path.Join("/", pkg, version, "SHA256SUMS")] = sha256sums.Bytes()
+	s.files[path.Join("/", pkg, version, "SHA256SUMS.sig")] = signature.Bytes()
+
+	s.versions[pkg] = append(s.versions[pkg], version)
+	index, err := json.Marshal(map[string][]string{"versions": s.versions[pkg]})
+	Ω(err).ShouldNot(HaveOccurred())
+	s.files[path.Join("/", pkg, "index.json")] = index
+}
+
+// ReleaseFile returns the release file of the package version or nil if the file is not served
+func (s *ReleaseServer) ReleaseFile(pkg, version, fileName string) []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.files[path.Join("/", pkg, version, fileName)]
+}
+
+// SetReleaseFile replaces the release file of the package version as is, SHA256SUMS and its signature are not updated.
+// The file is removed if data is nil.
+func (s *ReleaseServer) SetReleaseFile(pkg, version, fileName string, data []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if data == nil {
+		delete(s.files, path.Join("/", pkg, version, fileName))
+		return
+	}
+
+	s.files[path.Join("/", pkg, version, fileName)] = data
+}
+
+// SetReleaseFileStatus responds with the status instead of the release file of the package version.
+// The file is served again if the status is 0.
+func (s *ReleaseServer) SetReleaseFileStatus(pkg, version, fileName string, status int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if status == 0 {
+		delete(s.statuses, path.Join("/", pkg, version, fileName))
+		return
+	}
+
+	s.statuses[path.Join("/", pkg, version, fileName)] = status
+}
+
+// SetPackageIndex serves the index of the package with versions as is, release files of versions are not served
+func (s *ReleaseServer) SetPackageIndex(pkg string, versions []string) {
+	index, err := json.Marshal(map[string][]string{"versions": versions})
+	Ω(err).ShouldNot(HaveOccurred())
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.files[path.Join("/", pkg, "index.json")] = index
+}
+
+// ReposConfig returns the --repos value with the single HTTP repository of the server
+func (s *ReleaseServer) ReposConfig() string {
+	return fmt.Sprintf(`[{"type": "http", "endpoint": "%s/{package}"}]`, s.URL)
+}
+
+// WriteKeyring writes the public key that SHA256SUMS files are signed with
+func (s *ReleaseServer) WriteKeyring(path string) {
+	var keyring bytes.Buffer
+	Ω(s.signer.Serialize(&keyring)).Should(Succeed())
+	Ω(ioutil.WriteFile(path, keyring.Bytes(), 0644)).Should(Succeed())
+}
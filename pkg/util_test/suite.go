@@ -41,3 +41,23 @@ func BuildMultiwerfBinPath() string {
 	Ω(err).ShouldNot(HaveOccurred())
 	return path
 }
+
+// BuildMultiwerfBinPathWithSigningKey builds multiwerf with the pinned signing key (see PinnedSigningKey) to self-update it.
+// The version is used to serve the binary as a release, the default version is kept if it is empty.
+func BuildMultiwerfBinPathWithSigningKey(pinnedSigningKey, version string) string {
+	ldflags := fmt.Sprintf("-X github.com/werf/multiwerf/pkg/app.SelfSigningKey=%s", pinnedSigningKey)
+	if version != "" {
+		ldflags += fmt.Sprintf(" -X github.com/werf/multiwerf/pkg/app.Version=%s", version)
+	}
+
+	path, err := gexec.Build("github.com/werf/multiwerf/cmd/multiwerf", "-ldflags", ldflags)
+	Ω(err).ShouldNot(HaveOccurred())
+	return path
+}
+
+// BuildFakeWerfBinPath builds the werf stand-in that prints the version from its release file name
+func BuildFakeWerfBinPath() string {
+	path, err := gexec.Build("github.com/werf/multiwerf/pkg/util_test/fake_werf")
+	Ω(err).ShouldNot(HaveOccurred())
+	return path
+}
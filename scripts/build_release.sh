@@ -15,6 +15,11 @@ OS_ARCHS=(
 )
 BUILD_PACKAGE=github.com/werf/multiwerf/cmd/multiwerf
 VERSION_VAR_NAME=github.com/werf/multiwerf/pkg/app.Version
+SIGNING_KEY_VAR_NAME=github.com/werf/multiwerf/pkg/app.SelfSigningKey
+
+# the key is pinned into the binary and SHA256SUMS is signed with it to verify self-updates
+SIGNING_KEY_ID="${MULTIWERF_GPG_SIGNING_KEY_ID:?MULTIWERF_GPG_SIGNING_KEY_ID is required to sign the release}"
+SIGNING_KEY="$(gpg --export "$SIGNING_KEY_ID" | base64 | tr -d '\n')"
 
 if [[ -z "${1-}" ]] ; then
   echo "Usage: $0 VERSION"
@@ -40,13 +45,14 @@ for os_arch in "${OS_ARCHS[@]}"; do
   echo "# Building ${BASE_NAME} $VERSION for $os $arch ..."
 
   GOOS=${os} GOARCH=${arch} CGO_ENABLED=0 \
-  go build -ldflags="-s -w -X ${VERSION_VAR_NAME}=${VERSION} -X github.com/werf/multiwerf/pkg/app.OsArch=${os}-${arch}" \
+  go build -ldflags="-s -w -X ${VERSION_VAR_NAME}=${VERSION} -X ${SIGNING_KEY_VAR_NAME}=${SIGNING_KEY} -X github.com/werf/multiwerf/pkg/app.OsArch=${os}-${arch}" \
            -o "${outputFile}" "${BUILD_PACKAGE}"
 done
 
 (
 cd "$BUILD_DIR"
 sha256sum "${BASE_NAME}"-* > SHA256SUMS
+gpg --batch --yes --local-user "$SIGNING_KEY_ID" --detach-sign --output SHA256SUMS.sig SHA256SUMS
 )
 
 # save build date and commit
@@ -77,4 +83,5 @@ done
 (
 cd "${LATEST_DIR}"
 sha256sum "${BASE_NAME}"-* > SHA256SUMS
+gpg --batch --yes --local-user "$SIGNING_KEY_ID" --detach-sign --output SHA256SUMS.sig SHA256SUMS
 )
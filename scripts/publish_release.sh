@@ -71,7 +71,7 @@ main() {
   echo "  Upload to bintray"
   (
    cd "$RELEASE_BUILD_DIR/$VERSION"
-   for filename in "${BASE_NAME}"-* SHA256SUMS info.txt ; do
+   for filename in "${BASE_NAME}"-* SHA256SUMS SHA256SUMS.sig info.txt ; do
      echo "  - $filename"
      ( bintray_upload_file_into_version "$VERSION" "$filename" "$VERSION/$filename" ) || ( exit 1 )
    done
@@ -82,7 +82,7 @@ main() {
   echo "  Upload to github"
   (
    cd "$RELEASE_BUILD_DIR/$VERSION"
-   for filename in "${BASE_NAME}"-* SHA256SUMS info.txt ; do
+   for filename in "${BASE_NAME}"-* SHA256SUMS SHA256SUMS.sig info.txt ; do
      echo "  - $filename"
      ( github_upload_asset_for_release "$filename") || ( exit 1 )
    done